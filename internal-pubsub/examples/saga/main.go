@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"internal-pubsub/examples/subscriber/handler"
+	"internal-pubsub/examples/subscriber/model"
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/saga"
+)
+
+const (
+	TopicOrderCreated pubsub.Topic = "order.created"
+	TopicInvoiceGen   pubsub.Topic = "invoice.generated"
+)
+
+func main() {
+	opts := pubsub.DefaultOptions()
+	opts.DrainOnClose = true // Close รองานที่ค้างอยู่ให้เสร็จก่อน
+	bus := pubsub.New(opts)
+	invHandler := handler.NewInvoiceHandler(bus, TopicInvoiceGen)
+	mailHandler := handler.NewMailHandler()
+
+	// order -> invoice -> mail เป็น saga เดียว ถ้าส่งเมลไม่ได้ให้ยกเลิก invoice
+	orders := saga.New(bus, saga.NewMemoryStore(), saga.DefaultOptions())
+	err := orders.Register(saga.Definition{
+		Name: "order",
+		Steps: []saga.Step{
+			{
+				Name: "invoice",
+				On:   TopicOrderCreated,
+				Action: func(ctx context.Context, st *saga.State, ev pubsub.Event) error {
+					order, err := pubsub.DataAs[model.Order](ev)
+					if err != nil {
+						return err
+					}
+					st.Data["order"] = order.ID
+					return invHandler.CreateInvoice(ctx, order)
+				},
+				Compensate: func(ctx context.Context, st *saga.State) error {
+					fmt.Println("[invoice] cancel invoice for order:", st.Data["order"])
+					return nil
+				},
+			},
+			{
+				Name: "mail",
+				On:   TopicInvoiceGen,
+				Action: func(ctx context.Context, st *saga.State, ev pubsub.Event) error {
+					inv, err := pubsub.DataAs[model.Invoice](ev)
+					if err != nil {
+						return err
+					}
+					if inv.OrderID == "ORD-BAD" {
+						return errors.New("mailbox not found")
+					}
+					return mailHandler.SendMail(ctx, inv)
+				},
+			},
+		},
+		Timeout: 30 * time.Second,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := orders.Start(runCtx); err != nil {
+		log.Fatal(err)
+	}
+
+	// ใช้ order id เป็น correlation id เพื่อ query สถานะของ saga ด้วย order id ได้
+	orderCreated := pubsub.NewTypedTopic[model.Order](bus, TopicOrderCreated)
+	for _, o := range []model.Order{{ID: "ORD-123", User: "alice"}, {ID: "ORD-BAD", User: "bob"}} {
+		_ = orderCreated.Publish(pubsub.WithCorrelationID(context.Background(), o.ID), o)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := bus.Close(ctx); err != nil {
+		log.Println("bus close:", err)
+	}
+	stop()
+	if err := orders.Wait(); err != nil {
+		log.Println("saga:", err)
+	}
+
+	states, _ := orders.List(context.Background(), saga.Query{Saga: "order"})
+	for _, st := range states {
+		fmt.Printf("[saga] %s status=%s completed=%v reason=%q\n", st.ID, st.Status, st.Completed, st.Reason)
+	}
+}
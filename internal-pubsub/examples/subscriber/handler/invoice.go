@@ -8,21 +8,19 @@ import (
 )
 
 type InvoiceHandler interface {
-	CreateInvoice(ctx context.Context, ev pubsub.Event) error
+	CreateInvoice(ctx context.Context, order model.Order) error
 }
 
 type invoiceHandler struct {
-	bus             pubsub.Bus
-	topicInvoiceGen pubsub.Topic
+	invoiceGen pubsub.TypedTopic[model.Invoice]
 }
 
 func NewInvoiceHandler(bus pubsub.Bus, topicInvoiceGen pubsub.Topic) InvoiceHandler {
-	return &invoiceHandler{bus: bus, topicInvoiceGen: topicInvoiceGen}
+	return &invoiceHandler{invoiceGen: pubsub.NewTypedTopic[model.Invoice](bus, topicInvoiceGen)}
 }
 
-func (h *invoiceHandler) CreateInvoice(ctx context.Context, ev pubsub.Event) error {
-	order := ev.Data.(model.Order)
+func (h *invoiceHandler) CreateInvoice(ctx context.Context, order model.Order) error {
 	fmt.Println("[invoice] received order:", order.ID)
 	inv := model.Invoice{ID: "INV-001", OrderID: order.ID, Amount: 1990}
-	return h.bus.Publish(ctx, h.topicInvoiceGen, inv)
+	return h.invoiceGen.Publish(ctx, inv)
 }
@@ -8,7 +8,7 @@ import (
 )
 
 type MailHandler interface {
-	SendMail(ctx context.Context, ev pubsub.Event) error
+	SendMail(ctx context.Context, inv model.Invoice) error
 }
 
 type mailHandler struct {
@@ -19,8 +19,10 @@ func NewMailHandler() MailHandler {
 	return &mailHandler{}
 }
 
-func (h *mailHandler) SendMail(ctx context.Context, ev pubsub.Event) error {
-	inv := ev.Data.(model.Invoice)
+func (h *mailHandler) SendMail(ctx context.Context, inv model.Invoice) error {
 	fmt.Println("[mailer] send email for invoice:", inv.ID, "order:", inv.OrderID)
+	if ev, ok := pubsub.EventFromContext(ctx); ok {
+		fmt.Println("[mailer] correlation:", ev.CorrelationID(), "caused by:", ev.CausationID())
+	}
 	return nil
 }
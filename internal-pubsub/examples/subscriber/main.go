@@ -6,6 +6,7 @@ import (
 	"internal-pubsub/examples/subscriber/model"
 	"internal-pubsub/pkg/pubsub"
 	"internal-pubsub/pkg/subscriber"
+	"log"
 	"time"
 )
 
@@ -15,26 +16,44 @@ const (
 )
 
 func main() {
-	bus := pubsub.New(pubsub.DefaultOptions())
+	opts := pubsub.DefaultOptions()
+	opts.DrainOnClose = true // Close รองานที่ค้างอยู่ให้เสร็จก่อน
+	bus := pubsub.New(opts)
 
 	invHandler := handler.NewInvoiceHandler(bus, TopicInvoiceGen)
 	mailHandler := handler.NewMailHandler()
 
-	// Invoice service: ฟัง order.created -> สร้าง invoice -> publish invoice.generated
-	invSub := subscriber.New(bus, TopicOrderCreated, 8)
-	go invSub.Run(context.Background(), invHandler.CreateInvoice)
+	sup := subscriber.NewSupervisor(bus)
 
-	// Mailer: ฟัง invoice.generated (sequential)
-	mailSub := subscriber.New(bus, TopicInvoiceGen, 4, subscriber.WithStopOnError(false))
-	go mailSub.Run(context.Background(), mailHandler.SendMail)
+	// Invoice service: ฟัง order.created -> สร้าง invoice -> publish invoice.generated
+	sup.Add(TopicOrderCreated, 8, subscriber.Typed(invHandler.CreateInvoice))
 
-	// Publisher
-	_ = bus.Publish(context.Background(), TopicOrderCreated, model.Order{ID: "ORD-123", User: "alice"})
+	// Mailer: ฟัง invoice.generated (4 workers, คงลำดับต่อ order)
+	sup.AddConcurrent(TopicInvoiceGen, 4, 4, subscriber.Typed(mailHandler.SendMail),
+		subscriber.WithStopOnError(false),
+		subscriber.WithKeyFunc(func(ev pubsub.Event) string {
+			inv, _ := pubsub.DataAs[model.Invoice](ev)
+			return inv.OrderID
+		}),
+	)
 
-	time.Sleep(200 * time.Millisecond)
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	sup.Start(runCtx)
 
-	// Shutdown
-	_ = bus.Close(context.Background())
-	invSub.Close()
-	mailSub.Close()
+	// Publisher
+	orderCreated := pubsub.NewTypedTopic[model.Order](bus, TopicOrderCreated)
+	_ = orderCreated.Publish(context.Background(), model.Order{ID: "ORD-123", User: "alice"})
+
+	// Shutdown: รอให้ invoice -> mail ทำงานที่ค้างอยู่จนจบ (สูงสุด 2 วินาที)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := bus.Close(ctx); err != nil {
+		log.Println("bus close:", err)
+	}
+	// bus ปิดแล้ว subscriber ทุกตัวจะจบเอง; stop() กันกรณี Close หมดเวลา
+	stop()
+	if err := sup.Wait(); err != nil {
+		log.Println("supervisor:", err)
+	}
 }
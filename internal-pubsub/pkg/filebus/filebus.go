@@ -0,0 +1,398 @@
+// Package filebus คือ pubsub.Bus ที่เขียนทุก event ลง write-ahead log บนดิสก์
+// subscriber จึง replay ย้อนหลังและ resume จาก offset ที่บันทึกไว้ได้หลัง restart
+package filebus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// HeaderOffset header ที่บอก offset ของ event ใน log
+const HeaderOffset = "filebus-offset"
+
+const consumersDir = "consumers"
+
+// Bus implement pubsub.Bus บน segmented log ใน dir
+type Bus struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	segments []*segment // เรียงตาม base ตัวสุดท้ายคือ active
+	active   *os.File
+	next     uint64        // offset ถัดไปที่จะเขียน
+	notify   chan struct{} // ถูกปิดทุกครั้งที่มี record ใหม่
+	subs     map[*fileSub]struct{}
+	closed   bool
+	wg       sync.WaitGroup // tailer goroutine ของทุก subscription
+}
+
+var _ pubsub.Bus = (*Bus)(nil)
+
+// Open เปิด (หรือสร้าง) log ใน dir และกู้ segment สุดท้ายที่อาจเขียนค้างตอน crash
+func Open(dir string, opts Options) (*Bus, error) {
+	def := DefaultOptions()
+	if opts.DefaultBuffer <= 0 {
+		opts.DefaultBuffer = def.DefaultBuffer
+	}
+	if opts.SegmentMaxBytes <= 0 {
+		opts.SegmentMaxBytes = def.SegmentMaxBytes
+	}
+	if err := os.MkdirAll(filepath.Join(dir, consumersDir), 0o755); err != nil {
+		return nil, err
+	}
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bus{
+		dir:    dir,
+		opts:   opts,
+		notify: make(chan struct{}),
+		subs:   make(map[*fileSub]struct{}),
+	}
+	if len(segs) == 0 {
+		segs = []*segment{{base: 0, path: segmentPath(dir, 0), modTime: time.Now()}}
+	} else {
+		last := segs[len(segs)-1]
+		if b.next, err = recoverSegment(last); err != nil {
+			return nil, fmt.Errorf("filebus: recover %s: %w", last.path, err)
+		}
+	}
+	b.segments = segs
+	last := segs[len(segs)-1]
+	if b.active, err = os.OpenFile(last.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	if b.next < last.base {
+		b.next = last.base
+	}
+	return b, nil
+}
+
+// Subscribe รับเฉพาะ event ใหม่ (เหมือน memory bus) ใช้ SubscribeFrom เพื่อ replay
+// pubsub.WithConsumer ทำให้เป็น durable consumer (เหมือน SubscribeDurable จาก FromEnd)
+// เช่น subscriber.New(bus, topic, n, subscriber.WithSubscribeOptions(pubsub.WithConsumer("invoicing")))
+// WithGroup และ WithAck ทำตามไม่ได้ จึงได้ subscription ที่ปิดแล้วพร้อม log
+// โหมดการส่งไม่มีผล เพราะ event อยู่ใน log เสมอ ไม่มีการ drop หรือ timeout
+func (b *Bus) Subscribe(topic pubsub.Topic, buffer int, opts ...pubsub.SubscribeOption) pubsub.Subscription {
+	var so pubsub.SubscribeOptions
+	for _, f := range opts {
+		f(&so)
+	}
+	switch {
+	case so.Group != "":
+		log.Printf("[filebus] consumer group %q is not supported topic=%s", so.Group, topic)
+		return closedSub(topic)
+	case so.AckVisibilityMs > 0:
+		log.Printf("[filebus] ack mode is not supported topic=%s", topic)
+		return closedSub(topic)
+	case so.Consumer != "":
+		sub, err := b.SubscribeDurable(so.Consumer, topic, buffer, FromEnd())
+		if err != nil {
+			log.Printf("[filebus] subscribe topic=%s err=%v", topic, err)
+			return closedSub(topic)
+		}
+		return sub
+	}
+	return b.SubscribeFrom(topic, buffer, FromEnd())
+}
+
+// closedSub คืน subscription ที่ปิดแล้ว (range ออกทันที)
+func closedSub(topic pubsub.Topic) *fileSub {
+	s := &fileSub{topic: topic, ch: make(chan pubsub.Event), done: make(chan struct{}), stopped: true}
+	close(s.ch)
+	return s
+}
+
+// SubscribeFrom สมัครรับ topic (รองรับ wildcard) โดยเริ่มอ่านจาก from
+// ผู้รับไม่ทำให้ Publish บล็อก เพราะแต่ละ subscription อ่านจาก log ด้วย goroutine ของตัวเอง
+func (b *Bus) SubscribeFrom(topic pubsub.Topic, buffer int, from Position) pubsub.Subscription {
+	return b.subscribe("", topic, buffer, from)
+}
+
+// SubscribeDurable เหมือน SubscribeFrom แต่จำ offset ไว้ในชื่อ consumer
+// MarkDone จะบันทึก offset ลงดิสก์ ครั้งถัดไปที่ subscribe ด้วยชื่อเดิมจะอ่านต่อจากตรงนั้น
+// from ใช้เฉพาะเมื่อยังไม่เคยบันทึก offset
+func (b *Bus) SubscribeDurable(consumer string, topic pubsub.Topic, buffer int, from Position) (pubsub.Subscription, error) {
+	if consumer == "" || strings.ContainsAny(consumer, `/\`) {
+		return nil, fmt.Errorf("filebus: invalid consumer name %q", consumer)
+	}
+	raw, err := os.ReadFile(b.offsetPath(consumer))
+	switch {
+	case err == nil:
+		next, perr := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+		if perr != nil {
+			return nil, fmt.Errorf("filebus: read offset of %s: %w", consumer, perr)
+		}
+		from = FromOffset(next)
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	return b.subscribe(consumer, topic, buffer, from), nil
+}
+
+func (b *Bus) subscribe(consumer string, topic pubsub.Topic, buffer int, from Position) pubsub.Subscription {
+	if buffer <= 0 {
+		buffer = b.opts.DefaultBuffer
+	}
+	s := &fileSub{
+		bus: b, topic: topic, consumer: consumer,
+		ch:   make(chan pubsub.Event, buffer),
+		done: make(chan struct{}),
+	}
+	if consumer != "" {
+		s.finished = make(map[uint64]struct{})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		s.stopped = true
+		return s
+	}
+	start := b.resolveLocked(from)
+	b.subs[s] = struct{}{}
+	b.wg.Add(1)
+	go s.tail(start)
+	return s
+}
+
+// resolveLocked แปลง Position เป็นตำแหน่งเริ่มอ่าน
+func (b *Bus) resolveLocked(from Position) cursor {
+	first := b.segments[0]
+	active := b.segments[len(b.segments)-1]
+	switch from.kind {
+	case posBeginning:
+		return cursor{segBase: first.base}
+	case posOffset:
+		c := cursor{segBase: first.base, next: from.offset}
+		for _, seg := range b.segments {
+			if seg.base <= from.offset {
+				c.segBase = seg.base
+			}
+		}
+		return c
+	case posTime:
+		// segment ที่เขียนครั้งสุดท้ายก่อน t ไม่มี event ที่ต้องการแน่นอน
+		c := cursor{segBase: active.base, since: from.time}
+		for i := len(b.segments) - 1; i >= 0; i-- {
+			if b.segments[i].modTime.Before(from.time) {
+				break
+			}
+			c.segBase = b.segments[i].base
+		}
+		return c
+	default: // posEnd
+		return cursor{segBase: active.base, pos: active.size, next: b.next}
+	}
+}
+
+// Publish เขียน event ลง log แล้วปลุก subscription ทั้งหมด
+// Data ต้อง encode ได้ด้วย Options.Codec (ถ้า encode ไม่ได้จะคืน *pubsub.EncodeError)
+func (b *Bus) Publish(ctx context.Context, topic pubsub.Topic, data any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ev := pubsub.NewEvent(ctx, topic, data)
+	enc, err := pubsub.EncodeEvent(b.opts.Codec, ev)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return pubsub.ErrClosed
+	}
+	buf, err := encodeRecord(record{Offset: b.next, Encoded: enc})
+	if err != nil {
+		return err
+	}
+	seg := b.segments[len(b.segments)-1]
+	if _, err := b.active.Write(buf); err != nil {
+		b.truncateLocked(seg)
+		return fmt.Errorf("filebus: write: %w", err)
+	}
+	if b.opts.SyncWrites {
+		if err := b.active.Sync(); err != nil {
+			b.truncateLocked(seg)
+			return fmt.Errorf("filebus: sync: %w", err)
+		}
+	}
+	seg.size += int64(len(buf))
+	seg.modTime = ev.Time
+	b.next++
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+
+	if seg.size >= b.opts.SegmentMaxBytes {
+		if err := b.rollLocked(); err != nil {
+			return err
+		}
+		b.compactLocked()
+	}
+	return nil
+}
+
+// truncateLocked ตัด byte ที่เขียนค้างจาก Publish ที่ล้มเหลวออก ให้ record ถัดไปต่อจาก record ที่สมบูรณ์
+func (b *Bus) truncateLocked(seg *segment) {
+	if err := b.active.Truncate(seg.size); err != nil {
+		log.Printf("[filebus] truncate segment base=%d size=%d err=%v", seg.base, seg.size, err)
+	}
+}
+
+// rollLocked ปิด segment ปัจจุบันและเริ่ม segment ใหม่ที่ base = offset ถัดไป
+func (b *Bus) rollLocked() error {
+	if err := b.active.Sync(); err != nil {
+		return err
+	}
+	if err := b.active.Close(); err != nil {
+		return err
+	}
+	seg := &segment{base: b.next, path: segmentPath(b.dir, b.next), modTime: time.Now()}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("filebus: roll segment: %w", err)
+	}
+	b.active = f
+	b.segments = append(b.segments, seg)
+	return nil
+}
+
+// Compact ลบ segment ที่ปิดแล้วตาม Retention (เรียกอัตโนมัติทุกครั้งที่ขึ้น segment ใหม่)
+func (b *Bus) Compact() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.compactLocked()
+}
+
+func (b *Bus) compactLocked() {
+	ret := b.opts.Retention
+	if ret.MaxAge <= 0 && ret.MaxBytes <= 0 {
+		return
+	}
+	var total int64
+	for _, seg := range b.segments {
+		total += seg.size
+	}
+	cutoff := time.Now().Add(-ret.MaxAge)
+	for len(b.segments) > 1 {
+		oldest := b.segments[0]
+		expired := ret.MaxAge > 0 && oldest.modTime.Before(cutoff)
+		oversize := ret.MaxBytes > 0 && total > ret.MaxBytes
+		if !expired && !oversize {
+			break
+		}
+		// tailer ที่เปิดไฟล์ค้างไว้ยังอ่านต่อได้ ส่วนที่ยังไม่เปิดจะข้ามไป segment ถัดไป
+		_ = os.Remove(oldest.path)
+		total -= oldest.size
+		b.segments = b.segments[1:]
+	}
+}
+
+// Close หยุดรับ Publish ปิดทุก subscription และปิดไฟล์
+// event ทั้งหมดอยู่ใน log แล้ว consumer แบบ durable จะอ่านต่อได้เมื่อเปิดใหม่
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	subs := make([]*fileSub, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if serr := b.active.Sync(); serr != nil && err == nil {
+		err = serr
+	}
+	if cerr := b.active.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// view คืนสถานะของ segment base สำหรับ tailer
+type segmentView struct {
+	exists   bool
+	size     int64
+	sealed   bool   // ไม่ใช่ active แล้ว ขนาดจะไม่เปลี่ยนอีก
+	nextBase uint64 // base ของ segment ถัดไป (ใช้เมื่อ sealed หรือ !exists)
+	notify   <-chan struct{}
+}
+
+func (b *Bus) view(base uint64) segmentView {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v := segmentView{notify: b.notify, nextBase: b.segments[0].base}
+	for i, seg := range b.segments {
+		if seg.base == base {
+			v.exists = true
+			v.size = seg.size
+			v.sealed = i < len(b.segments)-1
+			if v.sealed {
+				v.nextBase = b.segments[i+1].base
+			}
+			return v
+		}
+		if seg.base > base {
+			// segment ถูก compact ไปแล้ว ข้ามไปตัวถัดไปที่ยังอยู่
+			v.nextBase = seg.base
+			return v
+		}
+	}
+	return v
+}
+
+func (b *Bus) offsetPath(consumer string) string {
+	return filepath.Join(b.dir, consumersDir, consumer+".offset")
+}
+
+// commitOffset บันทึก offset ถัดไปของ consumer แบบ atomic (เขียนไฟล์ชั่วคราวแล้ว rename)
+func (b *Bus) commitOffset(consumer string, next uint64) error {
+	path := b.offsetPath(consumer)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(next, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (b *Bus) removeSub(s *fileSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
@@ -0,0 +1,270 @@
+package filebus
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/subscriber"
+)
+
+type order struct{ ID string }
+
+func init() { gob.Register(order{}) }
+
+func recv(t *testing.T, sub pubsub.Subscription) pubsub.Event {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.C():
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return pubsub.Event{}
+}
+
+func TestReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	bus, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, id := range []string{"ORD-1", "ORD-2", "ORD-3"} {
+		if err := bus.Publish(ctx, "order.created", order{ID: id}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	_ = bus.Publish(ctx, "invoice.generated", "INV-1")
+	if err := bus.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	bus, err = Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bus.Close(ctx)
+
+	sub := bus.SubscribeFrom("order.*", 8, FromBeginning())
+	for _, want := range []string{"ORD-1", "ORD-2", "ORD-3"} {
+		ev := recv(t, sub)
+		if got := ev.Data.(order).ID; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	// หลัง replay แล้วต้องได้ event ใหม่ต่อ
+	_ = bus.Publish(ctx, "order.created", order{ID: "ORD-4"})
+	ev := recv(t, sub)
+	if ev.Data.(order).ID != "ORD-4" || ev.Headers[HeaderOffset] != "4" {
+		t.Fatalf("unexpected live event %+v", ev)
+	}
+	// subscription ที่ไม่ durable ไม่ต้องจำ offset ที่ส่งไปแล้ว
+	fs := sub.(*fileSub)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.inflight) != 0 {
+		t.Fatalf("non-durable subscription tracks %d offsets", len(fs.inflight))
+	}
+}
+
+func TestDurableConsumerResumes(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	bus, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := range 4 {
+		_ = bus.Publish(ctx, "order.created", i)
+	}
+
+	sub, err := bus.SubscribeDurable("invoicing", "order.created", 4, FromBeginning())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for range 2 {
+		sub.(pubsub.DoneMarker).MarkDone(recv(t, sub))
+	}
+	_ = bus.Close(ctx)
+
+	bus, err = Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bus.Close(ctx)
+	sub, err = bus.SubscribeDurable("invoicing", "order.created", 4, FromBeginning())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if ev := recv(t, sub); ev.Data != 2 {
+		t.Fatalf("resumed at %v, want 2", ev.Data)
+	}
+}
+
+func TestSubscriberGetsDurableOffsetsThroughOptions(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	quiet := subscriber.WithLogger(log.New(io.Discard, "", 0))
+	durable := subscriber.WithSubscribeOptions(pubsub.WithConsumer("invoicing"))
+
+	// run ประมวลผลด้วย subscriber มาตรฐานจนได้ n event แล้วปิด bus
+	run := func(publish []int, n int) []any {
+		t.Helper()
+		bus, err := Open(dir, DefaultOptions())
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		for _, i := range publish {
+			_ = bus.Publish(ctx, "order.created", i)
+		}
+		sub := subscriber.New(bus, "order.created", 4, quiet, durable)
+		got := make(chan any, n)
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = sub.Run(runCtx, func(ctx context.Context, ev pubsub.Event) error {
+				got <- ev.Data
+				return nil
+			})
+		}()
+		if len(publish) == 0 {
+			for i := range n {
+				_ = bus.Publish(ctx, "order.created", i)
+			}
+		}
+		var out []any
+		for range n {
+			select {
+			case v := <-got:
+				out = append(out, v)
+			case <-time.After(time.Second):
+				t.Fatalf("got only %v", out)
+			}
+		}
+		cancel()
+		<-done
+		_ = bus.Close(ctx)
+		return out
+	}
+
+	run(nil, 2)
+	// event ที่ publish ระหว่างไม่มี consumer ต้องได้หลังกลับมา (ไม่ใช่เริ่มจากปลาย log ใหม่)
+	if got := run([]int{2}, 1); got[0] != 2 {
+		t.Fatalf("resumed with %v, want 2", got)
+	}
+
+	bus, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(ctx)
+	if _, ok := <-bus.Subscribe("order.created", 1, pubsub.WithGroup("invoicing")).C(); ok {
+		t.Fatal("group subscription should be rejected")
+	}
+}
+
+func TestDurableOffsetWaitsForEarlierEvents(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	bus, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := range 3 {
+		_ = bus.Publish(ctx, "order.created", i)
+	}
+
+	// resume รับ event ทั้งสามแล้ว MarkDone ตามลำดับ finished (เหมือน worker หลายตัว) แล้วเปิด bus ใหม่
+	// คืน data ของ event แรกที่ได้รับ
+	resume := func(finished ...int) any {
+		t.Helper()
+		sub, err := bus.SubscribeDurable("invoicing", "order.created", 4, FromBeginning())
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		evs := make([]pubsub.Event, 3)
+		for i := range evs {
+			evs[i] = recv(t, sub)
+		}
+		for _, i := range finished {
+			sub.(pubsub.DoneMarker).MarkDone(evs[i])
+		}
+		_ = bus.Close(ctx)
+		if bus, err = Open(dir, DefaultOptions()); err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		return evs[0].Data
+	}
+
+	// 1 และ 2 เสร็จก่อน 0 ที่ยังทำอยู่: offset ต้องไม่ขยับ
+	resume(2, 1)
+	if got := resume(1, 0); got != 0 {
+		t.Fatalf("resumed at %v, want 0", got)
+	}
+	defer bus.Close(ctx)
+	sub, err := bus.SubscribeDurable("invoicing", "order.created", 4, FromBeginning())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if ev := recv(t, sub); ev.Data != 2 {
+		t.Fatalf("resumed at %v, want 2", ev.Data)
+	}
+}
+
+func TestReplayFromTime(t *testing.T) {
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.SegmentMaxBytes = 1 // หนึ่ง record ต่อ segment
+
+	bus, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(ctx)
+
+	_ = bus.Publish(ctx, "order.created", 0)
+	time.Sleep(5 * time.Millisecond)
+	since := time.Now()
+	_ = bus.Publish(ctx, "order.created", 1)
+	_ = bus.Publish(ctx, "order.created", 2)
+
+	sub := bus.SubscribeFrom("order.created", 4, FromTime(since))
+	for _, want := range []int{1, 2} {
+		if ev := recv(t, sub); ev.Data != want {
+			t.Fatalf("got %v, want %d", ev.Data, want)
+		}
+	}
+}
+
+func TestRetentionCompactsSealedSegments(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	opts := DefaultOptions()
+	opts.SegmentMaxBytes = 1
+	opts.Retention.MaxBytes = 1
+
+	bus, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bus.Close(ctx)
+
+	for i := range 3 {
+		_ = bus.Publish(ctx, "order.created", i)
+	}
+	segs, _ := listSegments(dir)
+	if len(segs) != 1 || segs[0].base != 3 {
+		t.Fatalf("expected only the active segment after compaction, got %+v", segs)
+	}
+}
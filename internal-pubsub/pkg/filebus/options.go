@@ -0,0 +1,62 @@
+package filebus
+
+import (
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Options ปรับแต่งพฤติกรรมของ file bus
+type Options struct {
+	DefaultBuffer   int       // ความจุ chan เริ่มต้นของ subscriber (ถ้าไม่ระบุ)
+	SegmentMaxBytes int64     // ขนาดสูงสุดของ segment ก่อนขึ้นไฟล์ใหม่
+	SyncWrites      bool      // fsync ทุกครั้งที่ Publish (ทนทานกว่าแต่ช้ากว่า)
+	Retention       Retention // นโยบายลบ segment เก่า
+
+	// Codec แปลง Event.Data ก่อนเขียนลง log (nil = pubsub.DefaultRegistry)
+	// ต้องใช้ codec ที่ถอด log เดิมได้ตลอดอายุของข้อมูล
+	Codec pubsub.PayloadCodec
+}
+
+// Retention กำหนดว่าจะเก็บ segment ที่ปิดแล้วไว้นานแค่ไหน (ค่า 0 = ไม่จำกัด)
+// segment ที่กำลังเขียนอยู่จะไม่ถูกลบเสมอ
+type Retention struct {
+	MaxAge   time.Duration // ลบ segment ที่เขียนครั้งสุดท้ายเก่ากว่านี้
+	MaxBytes int64         // ลบ segment เก่าสุดจนขนาดรวมไม่เกินนี้
+}
+
+// DefaultOptions ค่าปริยาย
+func DefaultOptions() Options {
+	return Options{
+		DefaultBuffer:   1,
+		SegmentMaxBytes: 16 << 20,
+	}
+}
+
+type positionKind int
+
+const (
+	posEnd positionKind = iota
+	posBeginning
+	posOffset
+	posTime
+)
+
+// Position จุดเริ่มอ่านของ subscription
+type Position struct {
+	kind   positionKind
+	offset uint64
+	time   time.Time
+}
+
+// FromEnd รับเฉพาะ event ใหม่หลังจาก subscribe (เหมือน memory bus)
+func FromEnd() Position { return Position{kind: posEnd} }
+
+// FromBeginning replay ตั้งแต่ event แรกที่ยังเก็บอยู่
+func FromBeginning() Position { return Position{kind: posBeginning} }
+
+// FromOffset เริ่มที่ offset ที่กำหนด (รวม offset นั้นด้วย)
+func FromOffset(offset uint64) Position { return Position{kind: posOffset, offset: offset} }
+
+// FromTime replay event ที่ publish ตั้งแต่เวลา t เป็นต้นไป
+func FromTime(t time.Time) Position { return Position{kind: posTime, time: t} }
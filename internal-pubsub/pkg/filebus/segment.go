@@ -0,0 +1,145 @@
+package filebus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+const (
+	segmentExt  = ".wal"
+	headerBytes = 8 // ความยาว payload (4) + crc32 (4)
+)
+
+var errCorrupt = errors.New("filebus: corrupt record")
+
+// segment คือไฟล์ log หนึ่งไฟล์ ตั้งชื่อตาม offset แรกของไฟล์
+type segment struct {
+	base    uint64
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// record คือสิ่งที่ถูกเขียนลง log ต่อหนึ่ง event (Data ผ่าน Options.Codec แล้ว)
+type record struct {
+	Offset  uint64
+	Encoded pubsub.EncodedEvent
+}
+
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// listSegments คืน segment ทั้งหมดใน dir เรียงตาม base
+func listSegments(dir string) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []*segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, &segment{
+			base: base, path: filepath.Join(dir, name),
+			size: info.Size(), modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].base < segs[j].base })
+	return segs, nil
+}
+
+// encodeRecord: [len uint32][crc32 uint32][gob record]
+func encodeRecord(r record) ([]byte, error) {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&r); err != nil {
+		return nil, fmt.Errorf("filebus: encode offset=%d topic=%s: %w", r.Offset, r.Encoded.Topic, err)
+	}
+	buf := make([]byte, headerBytes, headerBytes+payload.Len())
+	binary.BigEndian.PutUint32(buf[0:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+	return append(buf, payload.Bytes()...), nil
+}
+
+// readRecord อ่าน record ถัดไป คืนจำนวน byte ที่อ่าน
+// คืน io.EOF เมื่อหมดพอดี และ io.ErrUnexpectedEOF เมื่อเจอ record ที่เขียนไม่ครบ
+func readRecord(r io.Reader) (record, int64, error) {
+	var hdr [headerBytes]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return record{}, 0, err
+	}
+	size := binary.BigEndian.Uint32(hdr[0:4])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return record{}, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(hdr[4:8]) {
+		return record{}, 0, errCorrupt
+	}
+	var rec record
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return record{}, 0, fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	return rec, headerBytes + int64(size), nil
+}
+
+// recoverSegment อ่าน segment จนถึง record สุดท้ายที่สมบูรณ์ แล้วตัดส่วนที่เสียทิ้ง
+// คืน offset ถัดไปที่ต้องใช้
+func recoverSegment(seg *segment) (uint64, error) {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	next := seg.base
+	var good int64
+	r := bufio.NewReader(f)
+	for {
+		rec, n, err := readRecord(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errCorrupt) {
+				// เขียนค้างตอน crash: ตัดทิ้ง
+				if terr := f.Truncate(good); terr != nil {
+					return 0, terr
+				}
+				break
+			}
+			return 0, err
+		}
+		good += n
+		next = rec.Offset + 1
+	}
+	seg.size = good
+	return next, nil
+}
@@ -0,0 +1,196 @@
+package filebus
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// cursor ตำแหน่งอ่านของ tailer
+type cursor struct {
+	segBase uint64    // segment ที่กำลังอ่าน
+	pos     int64     // byte offset ภายใน segment
+	next    uint64    // ข้าม record ที่ offset < next
+	since   time.Time // ข้าม record ที่ publish ก่อนเวลานี้
+}
+
+type fileSub struct {
+	bus      *Bus
+	topic    pubsub.Topic
+	consumer string // ว่าง = ไม่ durable
+	ch       chan pubsub.Event
+	done     chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	inflight []uint64            // offset ที่ส่งออกไปแล้วแต่ยังไม่บันทึก เรียงตามลำดับ (เฉพาะ durable consumer)
+	finished map[uint64]struct{} // offset ใน inflight ที่ MarkDone แล้ว
+	stopped  bool
+}
+
+func (s *fileSub) C() <-chan pubsub.Event { return s.ch }
+
+func (s *fileSub) Unsubscribe() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		stopped := s.stopped
+		s.mu.Unlock()
+		if !stopped {
+			close(s.done)
+		}
+	})
+}
+
+// MarkDone ยืนยันว่าประมวลผล ev (ระบุด้วย HeaderOffset) เสร็จแล้ว เรียกลำดับใดก็ได้
+// offset ที่บันทึกลงดิสก์ขยับได้เฉพาะเมื่อทุก event ก่อนหน้าเสร็จแล้ว event ที่ยังทำอยู่จึงไม่ถูกข้ามหลัง restart
+// subscription ที่ไม่ durable ไม่มี offset ให้บันทึก จึงไม่มีผล
+func (s *fileSub) MarkDone(ev pubsub.Event) {
+	if s.consumer == "" {
+		return
+	}
+	offset, err := strconv.ParseUint(ev.Headers[HeaderOffset], 10, 64)
+	if err != nil {
+		return
+	}
+	// ถือ lock ระหว่างบันทึก เพื่อไม่ให้การบันทึกที่มาทีหลังเขียนทับด้วย offset ที่น้อยกว่า
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.inflight, offset) {
+		return
+	}
+	s.finished[offset] = struct{}{}
+	n := 0
+	for n < len(s.inflight) {
+		if _, ok := s.finished[s.inflight[n]]; !ok {
+			break
+		}
+		delete(s.finished, s.inflight[n])
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	next := s.inflight[n-1] + 1
+	s.inflight = s.inflight[n:]
+	if err := s.bus.commitOffset(s.consumer, next); err != nil {
+		log.Printf("[filebus] commit offset consumer=%s offset=%d err=%v", s.consumer, next, err)
+	}
+}
+
+// tail อ่าน log จาก c ไปเรื่อย ๆ และส่ง event ที่ตรง topic เข้า ch จนกว่าจะ Unsubscribe
+func (s *fileSub) tail(c cursor) {
+	defer s.bus.wg.Done()
+	defer s.bus.removeSub(s)
+	defer close(s.ch)
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	openBase := uint64(0)
+
+	for {
+		v := s.bus.view(c.segBase)
+		if !v.exists {
+			c.segBase, c.pos = v.nextBase, 0
+			continue
+		}
+		if f == nil || openBase != c.segBase {
+			if f != nil {
+				f.Close()
+				f = nil
+			}
+			var err error
+			if f, err = os.Open(segmentPath(s.bus.dir, c.segBase)); err != nil {
+				if os.IsNotExist(err) {
+					// ถูก compact ระหว่างทาง
+					continue
+				}
+				log.Printf("[filebus] open segment base=%d err=%v", c.segBase, err)
+				return
+			}
+			openBase = c.segBase
+		}
+
+		if c.pos < v.size {
+			r := bufio.NewReader(io.NewSectionReader(f, c.pos, v.size-c.pos))
+			for {
+				rec, n, err := readRecord(r)
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						log.Printf("[filebus] read segment base=%d pos=%d err=%v", c.segBase, c.pos, err)
+						return
+					}
+					break
+				}
+				c.pos += n
+				if !s.wants(rec, c) {
+					continue
+				}
+				c.next = rec.Offset + 1
+				ev, err := rec.Encoded.Decode(s.bus.opts.Codec)
+				if err != nil {
+					// ข้าม record ที่ถอดไม่ได้ (เช่นชนิดไม่ได้ลงทะเบียน) แทนที่จะหยุดทั้ง subscription
+					log.Printf("[filebus] skip offset=%d err=%v", rec.Offset, err)
+					continue
+				}
+				if !s.send(rec.Offset, ev) {
+					return
+				}
+			}
+			continue
+		}
+
+		if v.sealed {
+			c.segBase, c.pos = v.nextBase, 0
+			continue
+		}
+		// อ่านถึงปลาย log แล้ว รอ record ใหม่
+		select {
+		case <-v.notify:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *fileSub) wants(rec record, c cursor) bool {
+	if rec.Offset < c.next {
+		return false
+	}
+	if !c.since.IsZero() && rec.Encoded.Time.Before(c.since) {
+		return false
+	}
+	return pubsub.Match(s.topic, rec.Encoded.Topic)
+}
+
+// send คืน false เมื่อถูก Unsubscribe
+func (s *fileSub) send(offset uint64, ev pubsub.Event) bool {
+	if ev.Headers == nil {
+		ev.Headers = make(map[string]string, 1)
+	}
+	ev.Headers[HeaderOffset] = strconv.FormatUint(offset, 10)
+
+	if s.consumer != "" {
+		s.mu.Lock()
+		s.inflight = append(s.inflight, offset)
+		s.mu.Unlock()
+	}
+
+	select {
+	case s.ch <- ev:
+		return true
+	case <-s.done:
+		return false
+	}
+}
@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// fakeDB คือ driver ขนาดเล็กที่เข้าใจเฉพาะ SQL ที่ package นี้ใช้ เพื่อ test โดยไม่ต้องมี MySQL
+// transaction ทำงานทีละตัว (Begin ถือ lock จน Commit/Rollback) และ Rollback คืนข้อมูลเป็นตอน Begin
+type fakeDB struct {
+	txMu sync.Mutex // ถือตลอด transaction
+
+	mu         sync.Mutex
+	rows       []fakeRow
+	nextID     int64
+	failCommit atomic.Bool // ให้ Commit ครั้งถัดไปล้มเหลว
+}
+
+type fakeRow struct {
+	id                   int64
+	eventID, topic, hdrs string
+	payload              []byte
+	occurred             int64
+	sent                 *int64
+	failed               *int64
+	lastErr              string
+}
+
+var fakeSeq atomic.Int64
+
+// openFakeDB คืน *sql.DB ที่ใช้ fakeDB ตัวใหม่
+func openFakeDB() (*sql.DB, *fakeDB) {
+	f := &fakeDB{}
+	name := fmt.Sprintf("outbox-fake-%d", fakeSeq.Add(1))
+	sql.Register(name, f)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		panic(err)
+	}
+	return db, f
+}
+
+func (f *fakeDB) Open(string) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+
+func (f *fakeDB) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.rows {
+		if r.sent == nil && r.failed == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.rows)
+}
+
+type fakeConn struct {
+	db       *fakeDB
+	snapshot []fakeRow // ข้อมูลตอน Begin (nil = ไม่อยู่ใน transaction)
+	inTx     bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c: c, q: query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.txMu.Lock()
+	c.db.mu.Lock()
+	c.snapshot = slices.Clone(c.db.rows)
+	c.db.mu.Unlock()
+	c.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	defer c.end()
+	if c.db.failCommit.CompareAndSwap(true, false) {
+		c.restore()
+		return errors.New("fakedb: commit failed")
+	}
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	defer c.end()
+	c.restore()
+	return nil
+}
+
+func (c *fakeConn) restore() {
+	c.db.mu.Lock()
+	c.db.rows = c.snapshot
+	c.db.mu.Unlock()
+}
+
+func (c *fakeConn) end() {
+	c.snapshot, c.inTx = nil, false
+	c.db.txMu.Unlock()
+}
+
+type fakeStmt struct {
+	c *fakeConn
+	q string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	f := s.c.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.q, "INSERT INTO"):
+		f.nextID++
+		f.rows = append(f.rows, fakeRow{
+			id:       f.nextID,
+			eventID:  args[0].(string),
+			topic:    args[1].(string),
+			hdrs:     args[2].(string),
+			payload:  slices.Clone(args[3].([]byte)),
+			occurred: args[4].(int64),
+		})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.q, "UPDATE") && strings.Contains(s.q, "failed_at"):
+		failed, msg, id := args[0].(int64), args[1].(string), args[2].(int64)
+		for i := range f.rows {
+			if f.rows[i].id == id {
+				f.rows[i].failed, f.rows[i].lastErr = &failed, msg
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.q, "UPDATE"):
+		sent, id := args[0].(int64), args[1].(int64)
+		for i := range f.rows {
+			if f.rows[i].id == id {
+				f.rows[i].sent = &sent
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.q, "DELETE"):
+		before := args[0].(int64)
+		n := len(f.rows)
+		f.rows = slices.DeleteFunc(f.rows, func(r fakeRow) bool { return r.sent != nil && *r.sent <= before })
+		return driver.RowsAffected(n - len(f.rows)), nil
+	}
+	return nil, fmt.Errorf("fakedb: unsupported exec %q", s.q)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.q, "SELECT") {
+		return nil, fmt.Errorf("fakedb: unsupported query %q", s.q)
+	}
+	f := s.c.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	limit := int(args[0].(int64))
+	out := &fakeRows{}
+	for _, r := range f.rows {
+		if r.sent == nil && r.failed == nil && len(out.rows) < limit {
+			out.rows = append(out.rows, []driver.Value{r.id, r.eventID, r.topic, r.hdrs, r.payload, r.occurred})
+		}
+	}
+	return out, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id", "event_id", "topic", "headers", "payload", "occurred_at"}
+}
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
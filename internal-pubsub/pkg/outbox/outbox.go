@@ -0,0 +1,102 @@
+// Package outbox ทำ transactional outbox บน database/sql
+// ผู้ใช้เขียน event ลงตาราง outbox ใน transaction เดียวกับข้อมูลธุรกิจ (Outbox.Publish)
+// แล้ว Relay อ่านแถวที่ยังไม่ส่งไป publish บน pubsub.Bus และทำเครื่องหมายว่าส่งแล้ว
+// การส่งเป็นแบบ at-least-once: ถ้า process ตายหลัง publish แต่ก่อน commit event จะถูกส่งซ้ำ
+// ทุก event จึงมี pubsub.HeaderIdempotencyKey เป็น ID ของตัวเองให้ bus ที่เปิด Dedup ตัดซ้ำได้
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Options ปรับแต่ง Outbox
+type Options struct {
+	// Table ชื่อตาราง outbox (ค่าเริ่มต้น "pubsub_outbox") ถูกใส่ลง SQL ตรงๆ ห้ามมาจาก input ภายนอก
+	Table string
+	// Codec แปลง payload เป็น bytes (nil = pubsub.DefaultRegistry) ฝั่ง Relay ต้องใช้ codec ที่ถอดได้
+	Codec pubsub.PayloadCodec
+	// LockClause ต่อท้าย SELECT ของ Relay เพื่อให้หลาย relay ทำงานพร้อมกันได้โดยไม่หยิบแถวซ้ำ
+	// ค่าเริ่มต้น " FOR UPDATE SKIP LOCKED" (MySQL 8+) ใช้ "-" เพื่อไม่ใส่ (เช่น SQLite)
+	LockClause string
+}
+
+// DefaultOptions ค่าปริยาย
+func DefaultOptions() Options {
+	return Options{
+		Table:      "pubsub_outbox",
+		Codec:      pubsub.DefaultRegistry,
+		LockClause: " FOR UPDATE SKIP LOCKED",
+	}
+}
+
+// Execer คือสิ่งที่รัน SQL ได้ เช่น *sql.Tx (ปกติ) หรือ *sql.DB (เมื่อไม่ต้องการ transaction)
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Outbox เขียน event ลงตาราง outbox
+type Outbox struct {
+	opts Options
+}
+
+// New สร้าง Outbox ค่าที่ไม่ได้กำหนดใช้ DefaultOptions
+func New(opts Options) *Outbox {
+	def := DefaultOptions()
+	if opts.Table == "" {
+		opts.Table = def.Table
+	}
+	if opts.Codec == nil {
+		opts.Codec = def.Codec
+	}
+	switch opts.LockClause {
+	case "":
+		opts.LockClause = def.LockClause
+	case "-":
+		opts.LockClause = ""
+	}
+	return &Outbox{opts: opts}
+}
+
+// Schema คืนคำสั่งสร้างตาราง outbox สำหรับ MySQL
+// เวลาเก็บเป็น unix nano (BIGINT) sent_at เป็น NULL จนกว่าจะส่งสำเร็จ
+// แถวที่ถอด payload ไม่ได้จะมี failed_at และ last_error และไม่ถูกส่งอีก (ตั้ง failed_at เป็น NULL เพื่อส่งใหม่)
+func (o *Outbox) Schema() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL,
+	topic VARCHAR(255) NOT NULL,
+	headers TEXT NOT NULL,
+	payload LONGBLOB NOT NULL,
+	occurred_at BIGINT NOT NULL,
+	sent_at BIGINT NULL,
+	failed_at BIGINT NULL,
+	last_error TEXT NULL,
+	INDEX %[1]s_pending (sent_at, failed_at, id)
+)`, o.opts.Table)
+}
+
+// Publish เขียน event ลง outbox ผ่าน tx (event จะถูกส่งจริงเมื่อ tx commit แล้วเท่านั้น)
+// event สร้างด้วย pubsub.NewEvent จึงสืบทอด correlation/causation id และ header จาก ctx เหมือน Bus.Publish
+func (o *Outbox) Publish(ctx context.Context, tx Execer, topic pubsub.Topic, data any) error {
+	ev := pubsub.NewEvent(ctx, topic, data)
+	if ev.Headers[pubsub.HeaderIdempotencyKey] == "" {
+		ev.Headers[pubsub.HeaderIdempotencyKey] = ev.ID
+	}
+	enc, err := pubsub.EncodeEvent(o.opts.Codec, ev)
+	if err != nil {
+		return err
+	}
+	headers, err := json.Marshal(enc.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (event_id, topic, headers, payload, occurred_at) VALUES (?, ?, ?, ?, ?)", o.opts.Table),
+		enc.ID, string(enc.Topic), string(headers), enc.Data, enc.Time.UnixNano())
+	return err
+}
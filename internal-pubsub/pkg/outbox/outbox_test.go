@@ -0,0 +1,262 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+var quiet = log.New(io.Discard, "", 0)
+
+// manualClock คือ pubsub.Clock ที่เวลาเดินเมื่อ test สั่งเท่านั้น
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) pubsub.Timer { return time.AfterFunc(d, f) }
+
+func closeNow(bus pubsub.Bus) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = bus.Close(ctx)
+}
+
+func TestRelayPublishesOnlyCommittedEventsInOrder(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer closeNow(bus)
+	sub := bus.Subscribe("order.*", 8)
+
+	ob := New(Options{})
+	ctx := pubsub.WithCorrelationID(context.Background(), "req-1")
+
+	tx, _ := db.BeginTx(ctx, nil)
+	for _, id := range []string{"ORD-1", "ORD-2"} {
+		if err := ob.Publish(ctx, tx, "order.created", id); err != nil {
+			t.Fatalf("publish %s: %v", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	tx, _ = db.BeginTx(ctx, nil)
+	if err := ob.Publish(ctx, tx, "order.canceled", "ORD-3"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	_ = tx.Rollback()
+
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet})
+	if n, err := relay.Flush(context.Background()); err != nil || n != 2 {
+		t.Fatalf("flush = %d, %v; want 2 rows", n, err)
+	}
+	for _, want := range []string{"ORD-1", "ORD-2"} {
+		ev := <-sub.C()
+		if ev.Data != want || ev.CorrelationID() != "req-1" {
+			t.Fatalf("got %v (correlation %q), want %s with correlation req-1", ev.Data, ev.CorrelationID(), want)
+		}
+		if ev.Headers[pubsub.HeaderIdempotencyKey] != ev.ID {
+			t.Fatalf("idempotency key = %q, want event id %q", ev.Headers[pubsub.HeaderIdempotencyKey], ev.ID)
+		}
+	}
+	if fake.pending() != 0 {
+		t.Fatalf("pending = %d, want 0", fake.pending())
+	}
+	if n, _ := relay.Flush(context.Background()); n != 0 {
+		t.Fatalf("second flush read %d rows, want 0", n)
+	}
+}
+
+func TestRelayRedeliversWhenMarkIsLost(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.Options{Dedup: pubsub.DedupOptions{Window: time.Minute}})
+	defer closeNow(bus)
+	sub := bus.Subscribe("order.created", 8)
+
+	ob := New(Options{})
+	if err := ob.Publish(context.Background(), db, "order.created", "ORD-1"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet})
+	// publish สำเร็จแต่ commit ของการทำเครื่องหมายล้มเหลว (เหมือน process ตายก่อน commit)
+	fake.failCommit.Store(true)
+	if _, err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("flush: want commit error")
+	}
+	if fake.pending() != 1 {
+		t.Fatalf("pending = %d, want row kept for redelivery", fake.pending())
+	}
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if fake.pending() != 0 {
+		t.Fatalf("pending = %d, want 0", fake.pending())
+	}
+
+	// ส่งซ้ำด้วย idempotency key เดิม bus ที่เปิด Dedup จึงส่งให้ผู้รับแค่ครั้งเดียว
+	<-sub.C()
+	select {
+	case ev := <-sub.C():
+		t.Fatalf("duplicate delivered: %+v", ev)
+	default:
+	}
+	if got := bus.(pubsub.Inspector).Stats().Topics["order.created"].Suppressed; got != 1 {
+		t.Fatalf("suppressed = %d, want 1", got)
+	}
+}
+
+func TestRelayKeepsRowWhenBusRejects(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.DefaultOptions())
+	_ = bus.Close(context.Background())
+
+	ob := New(Options{})
+	_ = ob.Publish(context.Background(), db, "order.created", "ORD-1")
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet})
+	if _, err := relay.Flush(context.Background()); !errors.Is(err, pubsub.ErrClosed) {
+		t.Fatalf("flush err = %v, want ErrClosed", err)
+	}
+	if fake.pending() != 1 {
+		t.Fatalf("pending = %d, want 1", fake.pending())
+	}
+}
+
+func TestRelayMovesPoisonRowsOutOfQueue(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer closeNow(bus)
+	sub := bus.Subscribe("order.created", 8)
+
+	ob := New(Options{})
+	for _, id := range []string{"ORD-1", "ORD-2", "ORD-3"} {
+		_ = ob.Publish(context.Background(), db, "order.created", id)
+	}
+	// สองแถวแรกเสียเต็ม batch: ต้องไม่ขวาง ORD-3
+	fake.mu.Lock()
+	fake.rows[0].payload = []byte{0xff}
+	fake.rows[1].payload = []byte{0xff}
+	fake.mu.Unlock()
+
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet, BatchSize: 2})
+	if n, err := relay.Flush(context.Background()); err != nil || n != 2 {
+		t.Fatalf("flush = %d, %v; want 2 poison rows handled", n, err)
+	}
+	if n, err := relay.Flush(context.Background()); err != nil || n != 1 {
+		t.Fatalf("flush = %d, %v; want 1", n, err)
+	}
+	if ev := <-sub.C(); ev.Data != "ORD-3" {
+		t.Fatalf("got %v, want ORD-3", ev.Data)
+	}
+	if fake.pending() != 0 {
+		t.Fatalf("pending = %d, want 0", fake.pending())
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, r := range fake.rows[:2] {
+		if r.failed == nil || r.lastErr == "" || r.sent != nil {
+			t.Fatalf("row %d: failed=%v lastErr=%q sent=%v, want dead-lettered", r.id, r.failed, r.lastErr, r.sent)
+		}
+	}
+}
+
+func TestRelayCleanupHonoursRetention(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.Options{DeliveryMode: pubsub.DeliveryDrop})
+	defer closeNow(bus)
+
+	clock := &manualClock{now: time.Unix(1_700_000_000, 0)}
+	ob := New(Options{})
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet, Clock: clock, Retention: time.Hour})
+	_ = ob.Publish(context.Background(), db, "order.created", "ORD-1")
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	_ = ob.Publish(context.Background(), db, "order.created", "ORD-2") // ยังไม่ส่ง ห้ามลบ
+
+	if n, err := relay.Cleanup(context.Background()); err != nil || n != 0 {
+		t.Fatalf("cleanup = %d, %v; want nothing before retention", n, err)
+	}
+	clock.Advance(time.Hour)
+	if n, err := relay.Cleanup(context.Background()); err != nil || n != 1 {
+		t.Fatalf("cleanup = %d, %v; want 1", n, err)
+	}
+	if fake.count() != 1 || fake.pending() != 1 {
+		t.Fatalf("rows = %d pending = %d, want only the unsent row", fake.count(), fake.pending())
+	}
+}
+
+func TestRelayRetentionDefaultsAndNegative(t *testing.T) {
+	db, fake := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.Options{DeliveryMode: pubsub.DeliveryDrop})
+	defer closeNow(bus)
+
+	clock := &manualClock{now: time.Unix(1_700_000_000, 0)}
+	ob := New(Options{})
+	keep := ob.NewRelay(db, bus, RelayOptions{Logger: quiet, Clock: clock}) // 0 = ค่าเริ่มต้น 24h
+	purge := ob.NewRelay(db, bus, RelayOptions{Logger: quiet, Clock: clock, Retention: -1})
+	_ = ob.Publish(context.Background(), db, "order.created", "ORD-1")
+	if _, err := keep.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := keep.Cleanup(context.Background()); err != nil || n != 0 {
+		t.Fatalf("cleanup = %d, %v; zero Retention should keep rows for the default", n, err)
+	}
+	if n, err := purge.Cleanup(context.Background()); err != nil || n != 1 || fake.count() != 0 {
+		t.Fatalf("cleanup = %d, %v; negative Retention should delete sent rows", n, err)
+	}
+}
+
+func TestRelayRunDeliversUntilCanceled(t *testing.T) {
+	db, _ := openFakeDB()
+	defer db.Close()
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer closeNow(bus)
+	sub := bus.Subscribe("order.created", 8)
+
+	ob := New(Options{})
+	relay := ob.NewRelay(db, bus, RelayOptions{Logger: quiet, Interval: 5 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	_ = ob.Publish(context.Background(), db, "order.created", "ORD-1")
+	select {
+	case ev := <-sub.C():
+		if ev.Data != "ORD-1" {
+			t.Fatalf("got %v", ev.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay did not deliver")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run err = %v", err)
+	}
+}
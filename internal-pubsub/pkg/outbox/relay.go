@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// RelayOptions ปรับแต่ง Relay
+type RelayOptions struct {
+	Interval  time.Duration // ระยะห่างระหว่างรอบที่ตรวจหาแถวใหม่ (ค่าเริ่มต้น 1s)
+	BatchSize int           // จำนวนแถวสูงสุดต่อ transaction (ค่าเริ่มต้น 100)
+
+	// Retention ระยะเวลาเก็บแถวที่ส่งแล้วก่อนลบ (ค่าเริ่มต้น 24h, ติดลบ = ลบในรอบ cleanup ถัดไป)
+	Retention time.Duration
+	// CleanupInterval ระยะห่างระหว่างรอบลบแถวที่ส่งแล้ว (ค่าเริ่มต้น 1m, ติดลบ = ไม่ลบ)
+	CleanupInterval time.Duration
+
+	Clock  pubsub.Clock // nil = pubsub.SystemClock
+	Logger *log.Logger  // nil = log.Default()
+}
+
+// DefaultRelayOptions ค่าปริยาย
+func DefaultRelayOptions() RelayOptions {
+	return RelayOptions{
+		Interval:        time.Second,
+		BatchSize:       100,
+		Retention:       24 * time.Hour,
+		CleanupInterval: time.Minute,
+		Clock:           pubsub.SystemClock,
+		Logger:          log.Default(),
+	}
+}
+
+// Relay ส่ง event จากตาราง outbox ไปยัง bus ตามลำดับที่เขียน
+type Relay struct {
+	o    *Outbox
+	db   *sql.DB
+	bus  pubsub.Bus
+	opts RelayOptions
+}
+
+// NewRelay สร้าง Relay ที่อ่านจาก db (เช่นจาก db.NewMySqlDB) แล้ว publish บน bus
+func (o *Outbox) NewRelay(db *sql.DB, bus pubsub.Bus, opts RelayOptions) *Relay {
+	def := DefaultRelayOptions()
+	if opts.Interval <= 0 {
+		opts.Interval = def.Interval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.Retention == 0 {
+		opts.Retention = def.Retention
+	}
+	if opts.CleanupInterval == 0 {
+		opts.CleanupInterval = def.CleanupInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = def.Clock
+	}
+	if opts.Logger == nil {
+		opts.Logger = def.Logger
+	}
+	return &Relay{o: o, db: db, bus: bus, opts: opts}
+}
+
+// Run ส่ง event ทุก Interval และลบแถวเก่าทุก CleanupInterval จนกว่า ctx จะถูกยกเลิก
+// error ของฐานข้อมูลหรือ bus ถูก log แล้วลองใหม่รอบถัดไป
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	var cleanup <-chan time.Time
+	if r.opts.CleanupInterval > 0 {
+		t := time.NewTicker(r.opts.CleanupInterval)
+		defer t.Stop()
+		cleanup = t.C
+	}
+	for {
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.opts.Logger.Printf("[outbox] relay table=%s err=%v", r.o.opts.Table, err)
+				}
+				break
+			}
+			if n < r.opts.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-cleanup:
+			if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				r.opts.Logger.Printf("[outbox] cleanup table=%s err=%v", r.o.opts.Table, err)
+			}
+		}
+	}
+}
+
+// Flush ส่งแถวที่ยังไม่ส่งหนึ่ง batch ใน transaction เดียว คืนจำนวนแถวที่อ่านได้
+// ถ้า publish ล้มเหลวจะหยุดที่แถวนั้น (คงลำดับ) และ commit เฉพาะแถวก่อนหน้า
+// แถวที่ถอด payload ไม่ได้ถูกย้ายออกจากคิวด้วย failed_at (log ไว้) เพื่อไม่ให้ขวางแถวที่อยู่ถัดไป
+func (r *Relay) Flush(ctx context.Context) (n int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := r.pending(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	mark := fmt.Sprintf("UPDATE %s SET sent_at = ? WHERE id = ?", r.o.opts.Table)
+	fail := fmt.Sprintf("UPDATE %s SET failed_at = ?, last_error = ? WHERE id = ?", r.o.opts.Table)
+	var perr error
+	for _, row := range rows {
+		ev, derr := row.event.Decode(r.o.opts.Codec)
+		if derr != nil {
+			r.opts.Logger.Printf("[outbox] dead row id=%d event=%s err=%v", row.id, row.event.ID, derr)
+			if _, err = tx.ExecContext(ctx, fail, r.opts.Clock.Now().UnixNano(), derr.Error(), row.id); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if perr = r.publish(ctx, ev); perr != nil {
+			break
+		}
+		if _, err = tx.ExecContext(ctx, mark, r.opts.Clock.Now().UnixNano(), row.id); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rows), perr
+}
+
+type outboxRow struct {
+	id    int64
+	event pubsub.EncodedEvent
+}
+
+func (r *Relay) pending(ctx context.Context, tx *sql.Tx) ([]outboxRow, error) {
+	q := fmt.Sprintf("SELECT id, event_id, topic, headers, payload, occurred_at FROM %s WHERE sent_at IS NULL AND failed_at IS NULL ORDER BY id LIMIT ?%s",
+		r.o.opts.Table, r.o.opts.LockClause)
+	rs, err := tx.QueryContext(ctx, q, r.opts.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var out []outboxRow
+	for rs.Next() {
+		var (
+			row      outboxRow
+			topic    string
+			headers  string
+			occurred int64
+		)
+		if err := rs.Scan(&row.id, &row.event.ID, &topic, &headers, &row.event.Data, &occurred); err != nil {
+			return nil, err
+		}
+		row.event.Topic = pubsub.Topic(topic)
+		row.event.Time = time.Unix(0, occurred)
+		if err := json.Unmarshal([]byte(headers), &row.event.Headers); err != nil {
+			return nil, fmt.Errorf("outbox: row %d headers: %w", row.id, err)
+		}
+		out = append(out, row)
+	}
+	return out, rs.Err()
+}
+
+// publish ส่ง event โดยคง ID และ header เดิมเมื่อ bus รองรับ
+func (r *Relay) publish(ctx context.Context, ev pubsub.Event) error {
+	if ep, ok := r.bus.(pubsub.EventPublisher); ok {
+		return ep.PublishEvent(ctx, ev)
+	}
+	return r.bus.Publish(pubsub.WithHeaders(ctx, ev.Headers), ev.Topic, ev.Data)
+}
+
+// Cleanup ลบแถวที่ส่งแล้วนานกว่า Retention คืนจำนวนแถวที่ลบ
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	before := r.opts.Clock.Now().Add(-max(r.opts.Retention, 0)).UnixNano()
+	res, err := r.db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at <= ?", r.o.opts.Table), before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,198 @@
+package pubsub
+
+import "time"
+
+// Acker implement โดย Subscription ที่สมัครด้วย WithAck
+// ผู้รับต้องตัดสินทุก event ที่ได้จาก C() ด้วยหนึ่งในสามเมธอดนี้ event ที่ไม่ถูกตัดสินภายใน visibility
+// จะถูกส่งใหม่ (Event.Attempt เพิ่มขึ้น) event ถูกระบุด้วย ID และ Attempt จึงต้องส่ง event ตัวที่ได้รับมา
+// การตัดสิน event ของ attempt ที่หมด visibility ไปแล้ว หรือของ subscription ที่ไม่ได้ใช้ WithAck จะถูกเพิกเฉย
+type Acker interface {
+	// Ack ยืนยันว่าประมวลผลเสร็จแล้ว
+	Ack(ev Event)
+	// Nack ขอให้ส่งใหม่หลัง delay (0 = ส่งใหม่ทันที)
+	Nack(ev Event, delay time.Duration)
+	// Terminate เลิกส่ง event นี้โดยไม่ถือว่าสำเร็จ (นับใน Counters.Terminated)
+	Terminate(ev Event)
+}
+
+// ackTracker เก็บ event ที่ส่งแล้วแต่ยังไม่ถูกตัดสินของ subscription หนึ่งตัว (ป้องกันด้วย memSub.mu)
+type ackTracker struct {
+	visibility    time.Duration
+	maxDeliveries int
+	clock         Clock
+	entries       map[string]*ackEntry // key = Event.ID
+}
+
+type ackEntry struct {
+	ev      Event
+	delayed bool  // ถูก Nack แล้ว รอครบ delay ก่อนกลับเข้าคิว
+	timer   Timer // visibility หรือ delay ที่กำลังนับ (nil = ยังอยู่ระหว่างส่งเข้า chan)
+}
+
+func newAckTracker(so SubscribeOptions, clock Clock) *ackTracker {
+	if so.AckVisibilityMs <= 0 {
+		return nil
+	}
+	return &ackTracker{
+		visibility:    time.Duration(so.AckVisibilityMs) * time.Millisecond,
+		maxDeliveries: so.MaxDeliveries,
+		clock:         clock,
+		entries:       make(map[string]*ackEntry),
+	}
+}
+
+// outstanding จำนวน event ที่ยังไม่ถูกตัดสิน (nil-safe)
+func (t *ackTracker) outstanding() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.entries)
+}
+
+// stop หยุดทุก timer (nil-safe)
+func (t *ackTracker) stop() {
+	if t == nil {
+		return
+	}
+	for _, e := range t.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+	t.entries = make(map[string]*ackEntry)
+}
+
+// beginLocked ลงทะเบียน event ก่อนส่งเข้า chan (ผู้รับอาจ Ack ทันทีที่ได้รับ) คืน event ที่มี Attempt แล้ว
+func (s *memSub) beginLocked(ev Event) Event {
+	if ev.Attempt == 0 {
+		ev.Attempt = 1
+	}
+	s.ack.entries[ev.ID] = &ackEntry{ev: ev}
+	return ev
+}
+
+// cancelLocked ถอน event ที่ส่งเข้า chan ไม่สำเร็จ
+func (s *memSub) cancelLocked(ev Event) {
+	if e := s.ack.entries[ev.ID]; e != nil && e.ev.Attempt == ev.Attempt && e.timer == nil {
+		delete(s.ack.entries, ev.ID)
+		s.bus.settle()
+	}
+}
+
+// startLocked เริ่มนับ visibility หลังผู้รับได้ event ไปแล้ว
+func (s *memSub) startLocked(ev Event) {
+	e := s.ack.entries[ev.ID]
+	if e == nil || e.ev.Attempt != ev.Attempt || e.timer != nil {
+		return // ถูกตัดสินไปแล้วระหว่างส่ง
+	}
+	id, attempt := ev.ID, ev.Attempt
+	e.timer = s.ack.clock.AfterFunc(s.ack.visibility, func() { s.expire(id, attempt, false) })
+}
+
+// lookupLocked คืน entry ที่ผู้รับยังตัดสินได้ (ส่งแล้ว ยังไม่ถูก Nack และเป็น attempt ล่าสุด)
+func (s *memSub) lookupLocked(ev Event) *ackEntry {
+	if s.ack == nil || s.stopped {
+		return nil
+	}
+	e := s.ack.entries[ev.ID]
+	if e == nil || e.ev.Attempt != ev.Attempt || e.delayed {
+		return nil
+	}
+	return e
+}
+
+func (s *memSub) Ack(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.lookupLocked(ev); e != nil {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		delete(s.ack.entries, ev.ID)
+	}
+}
+
+func (s *memSub) Terminate(ev Event) {
+	s.mu.Lock()
+	e := s.lookupLocked(ev)
+	if e != nil {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		delete(s.ack.entries, ev.ID)
+	}
+	s.mu.Unlock()
+	if e != nil {
+		s.bus.settle()
+		s.stats.terminated.Add(1)
+		s.bus.topicCounters(ev.Topic).terminated.Add(1)
+	}
+}
+
+func (s *memSub) Nack(ev Event, delay time.Duration) {
+	s.mu.Lock()
+	e := s.lookupLocked(ev)
+	if e == nil {
+		s.mu.Unlock()
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	if delay <= 0 {
+		dropped := s.requeueLocked(e)
+		s.mu.Unlock()
+		s.afterRequeue(e.ev, dropped)
+		return
+	}
+	e.delayed = true
+	id, attempt := ev.ID, ev.Attempt
+	e.timer = s.ack.clock.AfterFunc(delay, func() { s.expire(id, attempt, true) })
+	s.mu.Unlock()
+}
+
+// expire ถูกเรียกเมื่อหมด visibility (delayed == false) หรือครบ delay ของ Nack (delayed == true)
+func (s *memSub) expire(id string, attempt int, delayed bool) {
+	s.mu.Lock()
+	if s.ack == nil || s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	e := s.ack.entries[id]
+	if e == nil || e.ev.Attempt != attempt || e.delayed != delayed {
+		s.mu.Unlock()
+		return
+	}
+	dropped := s.requeueLocked(e)
+	s.mu.Unlock()
+	s.afterRequeue(e.ev, dropped)
+}
+
+// requeueLocked ถอด entry แล้วใส่ event กลับเข้าคิวของ dispatcher เป็น attempt ถัดไป
+// คืน true ถ้าส่งครบ MaxDeliveries แล้วจึงทิ้งแทน
+func (s *memSub) requeueLocked(e *ackEntry) bool {
+	delete(s.ack.entries, e.ev.ID)
+	if s.ack.maxDeliveries > 0 && e.ev.Attempt >= s.ack.maxDeliveries {
+		return true
+	}
+	ev := e.ev
+	ev.Attempt++
+	// คิวยาวเกิน queueCap ได้ เพื่อไม่ให้ event ที่ต้องส่งใหม่หาย
+	s.queue = append(s.queue, ev)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return false
+}
+
+// afterRequeue นับผลหลังปล่อย lock (OnDrop เป็นโค้ดของผู้ใช้)
+func (s *memSub) afterRequeue(ev Event, dropped bool) {
+	if dropped {
+		s.bus.recordAckDrop(s, ev)
+		s.bus.settle()
+		return
+	}
+	s.stats.redelivered.Add(1)
+	s.bus.topicCounters(ev.Topic).redelivered.Add(1)
+}
@@ -0,0 +1,159 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, sub Subscription) Event {
+	t.Helper()
+	select {
+	case ev := <-sub.C():
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func expectNoEvent(t *testing.T, sub Subscription) {
+	t.Helper()
+	select {
+	case ev := <-sub.C():
+		t.Fatalf("unexpected event %v attempt=%d", ev.Data, ev.Attempt)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestAckRedeliversAfterVisibility(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 4, WithAck(time.Minute))
+	acker := sub.(Acker)
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	_ = bus.Publish(context.Background(), "order.created", "ORD-2")
+
+	first := nextEvent(t, sub)
+	second := nextEvent(t, sub)
+	if first.Attempt != 1 || second.Attempt != 1 {
+		t.Fatalf("attempts = %d, %d; want 1", first.Attempt, second.Attempt)
+	}
+	acker.Ack(second)
+
+	clock.Advance(time.Minute)
+	again := nextEvent(t, sub)
+	if again.ID != first.ID || again.Attempt != 2 {
+		t.Fatalf("redelivered %v attempt=%d, want %v attempt=2", again.Data, again.Attempt, first.Data)
+	}
+	// ack ของ attempt ที่หมดเวลาไปแล้วไม่มีผล
+	acker.Ack(first)
+	if got := subStats(bus).Unacked; got != 1 {
+		t.Fatalf("unacked = %d, want 1", got)
+	}
+	acker.Ack(again)
+
+	clock.Advance(time.Hour)
+	expectNoEvent(t, sub)
+	st := subStats(bus)
+	if st.Unacked != 0 || st.Redelivered != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestNackAndTerminate(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 4, WithAck(time.Minute))
+	acker := sub.(Acker)
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+
+	ev := nextEvent(t, sub)
+	acker.Nack(ev, 0)
+	ev = nextEvent(t, sub)
+	if ev.Attempt != 2 {
+		t.Fatalf("attempt = %d, want 2", ev.Attempt)
+	}
+
+	acker.Nack(ev, 10*time.Second)
+	clock.Advance(5 * time.Second)
+	expectNoEvent(t, sub)
+	clock.Advance(5 * time.Second)
+	ev = nextEvent(t, sub)
+	if ev.Attempt != 3 {
+		t.Fatalf("attempt = %d, want 3", ev.Attempt)
+	}
+
+	acker.Terminate(ev)
+	clock.Advance(time.Hour)
+	expectNoEvent(t, sub)
+	if st := subStats(bus); st.Terminated != 1 || st.Redelivered != 2 || st.Unacked != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestMaxDeliveriesDropsEvent(t *testing.T) {
+	clock := newFakeClock()
+	var dropped atomic.Value
+	bus := New(Options{Clock: clock, OnDrop: func(_ Topic, ev Event, reason DropReason) {
+		dropped.Store(reason)
+	}})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1, WithAck(time.Minute), WithMaxDeliveries(2))
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+
+	nextEvent(t, sub)
+	clock.Advance(time.Minute)
+	if ev := nextEvent(t, sub); ev.Attempt != 2 {
+		t.Fatalf("attempt = %d, want 2", ev.Attempt)
+	}
+	clock.Advance(time.Minute)
+	expectNoEvent(t, sub)
+	if got := dropped.Load(); got != DropMaxDeliveries {
+		t.Fatalf("drop reason = %v, want %v", got, DropMaxDeliveries)
+	}
+	if st := subStats(bus); st.Dropped != 1 || st.Unacked != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestCloseWaitsForAck(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DrainOnClose = true
+	bus := New(opts)
+	sub := bus.Subscribe("order.created", 1, WithAck(time.Minute))
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	ev := nextEvent(t, sub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var de *DrainError
+	if err := bus.Close(ctx); !errors.As(err, &de) || de.Abandoned != 1 {
+		t.Fatalf("close = %v, want DrainError with 1 abandoned", err)
+	}
+	// หลังปิดแล้ว Ack ไม่มีผลและไม่ panic
+	sub.(Acker).Ack(ev)
+
+	bus = New(opts)
+	sub = bus.Subscribe("order.created", 1, WithAck(time.Minute))
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	ev = nextEvent(t, sub)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sub.(Acker).Ack(ev)
+	}()
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func subStats(bus Bus) SubscriptionStats {
+	return bus.(Inspector).Stats().Subscriptions[0]
+}
@@ -0,0 +1,25 @@
+package pubsub
+
+import "time"
+
+// Clock แหล่งเวลาของ bus ใช้แทน time.Now/time.AfterFunc เพื่อให้ test กำหนดเวลาเองได้
+type Clock interface {
+	Now() time.Time
+	// AfterFunc เรียก f ใน goroutine ของตัวเองเมื่อครบ d (เหมือน time.AfterFunc)
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer คืนจาก Clock.AfterFunc
+type Timer interface {
+	// Stop ยกเลิก timer คืน false ถ้า f ถูกเรียกไปแล้วหรือ stop ไปแล้ว
+	Stop() bool
+}
+
+// SystemClock คือ Clock ที่ใช้เวลาจริง
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
@@ -0,0 +1,313 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Codec แปลงค่า Go เป็น bytes และกลับ
+type Codec interface {
+	Name() string // ชื่อที่เขียนติดไปกับ payload เพื่อให้ฝั่งอ่านเลือก codec ถูก
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error // v ต้องเป็น pointer
+}
+
+var (
+	// JSONCodec ใช้ encoding/json (อ่านง่าย ข้ามภาษาได้)
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec ใช้ encoding/gob แบบ interface ชนิดของค่าต้อง gob.Register ไว้ก่อน (ยกเว้นชนิดพื้นฐาน)
+	// Registry.RegisterType และ Registry.RegisterTopic ที่มี sample เรียก gob.Register ให้เอง
+	GobCodec Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal อ่านค่าที่ encode แบบ interface แล้วใส่ลง v ถ้าชนิดตรงกัน
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	var decoded any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return fmt.Errorf("gob: Unmarshal(non-pointer %T)", v)
+	}
+	if decoded == nil {
+		dst.Elem().SetZero()
+		return nil
+	}
+	src := reflect.ValueOf(decoded)
+	if !src.Type().AssignableTo(dst.Elem().Type()) {
+		return fmt.Errorf("gob: cannot assign %s to %s", src.Type(), dst.Elem().Type())
+	}
+	dst.Elem().Set(src)
+	return nil
+}
+
+// PayloadCodec แปลง Event.Data เป็น bytes และกลับ สำหรับส่งข้าม process หรือเก็บลงดิสก์
+// *Registry คือ implementation หลัก
+type PayloadCodec interface {
+	Encode(topic Topic, data any) ([]byte, error)
+	Decode(topic Topic, b []byte) (any, error)
+}
+
+// DefaultRegistry registry ที่ใช้เมื่อไม่ได้กำหนด codec (ค่าเริ่มต้นเป็น gob)
+var DefaultRegistry = NewRegistry(nil)
+
+// Registry เลือก codec และชนิดปลายทางของ payload ตาม topic หรือชนิด Go
+// ลำดับการเลือก: topic (exact ก่อน แล้วค่อย pattern ที่เจาะจงที่สุด) > ชนิดของค่า > codec เริ่มต้น
+// payload ที่ได้มีชื่อ codec และชื่อชนิดกำกับไว้ ฝั่งอ่านจึงคืนค่าเป็นชนิดเดิมได้เมื่อชนิดนั้นถูกลงทะเบียนไว้
+// (ไม่อย่างนั้นจะได้ค่าตามที่ codec ถอดเป็น any ได้ เช่น map[string]any ของ JSON)
+type Registry struct {
+	mu       sync.RWMutex
+	def      Codec
+	codecs   map[string]Codec
+	types    map[string]reflect.Type
+	byType   map[reflect.Type]Codec
+	topics   map[Topic]topicBinding
+	patterns []Topic // key ของ topics ที่เป็น pattern เรียงจากเจาะจงที่สุด (ดู comparePatterns)
+}
+
+type topicBinding struct {
+	codec Codec
+	typ   reflect.Type // nil = ไม่บังคับชนิด
+}
+
+// NewRegistry สร้าง registry ที่ใช้ def เมื่อไม่มีกฎใดตรง (nil = GobCodec) รู้จัก JSONCodec และ GobCodec อยู่แล้ว
+func NewRegistry(def Codec) *Registry {
+	if def == nil {
+		def = GobCodec
+	}
+	r := &Registry{
+		def:    def,
+		codecs: make(map[string]Codec),
+		types:  make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]Codec),
+		topics: make(map[Topic]topicBinding),
+	}
+	for _, c := range []Codec{JSONCodec, GobCodec, def} {
+		r.codecs[c.Name()] = c
+	}
+	return r
+}
+
+// RegisterCodec ทำให้ฝั่งอ่านรู้จัก codec ชื่อนี้ (codec ที่ใช้ใน RegisterTopic/RegisterType ถูกลงทะเบียนให้เอง)
+func (r *Registry) RegisterCodec(c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[c.Name()] = c
+}
+
+// RegisterType ใช้ codec c กับค่าชนิดเดียวกับ sample (c nil = codec เริ่มต้น)
+// และทำให้ Decode คืนค่าเป็นชนิดนี้ ชนิดนี้ถูก gob.Register ด้วยเพื่อให้ GobCodec ส่งได้
+// (panic เหมือน gob.Register ถ้าชนิดเคยลงทะเบียนกับ gob ไว้ด้วยชื่ออื่น)
+func (r *Registry) RegisterType(sample any, c Codec) {
+	t := reflect.TypeOf(sample)
+	if sample != nil {
+		gob.Register(sample)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[typeName(t)] = t
+	if c != nil {
+		r.byType[t] = c
+		r.codecs[c.Name()] = c
+	}
+}
+
+// RegisterTopic ใช้ codec c กับทุก event ของ topic (เป็น pattern ได้) (c nil = codec เริ่มต้น)
+// ถ้า sample ไม่ใช่ nil payload ของ topic นี้ต้องเป็นชนิดเดียวกับ sample และ Decode จะคืนค่าชนิดนี้เสมอ
+// (ชนิดของ sample ถูก gob.Register เหมือน RegisterType)
+// ถ้าหลาย pattern ตรงกับ topic เดียวกัน ใช้ตัวที่เจาะจงที่สุด (มีระดับที่เป็นคำจริงมากกว่า)
+func (r *Registry) RegisterTopic(topic Topic, c Codec, sample any) {
+	b := topicBinding{codec: c}
+	if sample != nil {
+		b.typ = reflect.TypeOf(sample)
+		gob.Register(sample)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.topics[topic]; !exists && topic.IsPattern() {
+		r.patterns = append(r.patterns, topic)
+		slices.SortFunc(r.patterns, comparePatterns)
+	}
+	r.topics[topic] = b
+	if c != nil {
+		r.codecs[c.Name()] = c
+	}
+	if b.typ != nil {
+		r.types[typeName(b.typ)] = b.typ
+	}
+}
+
+// binding คืนกฎของ topic (exact ก่อน แล้วค่อย pattern ที่เจาะจงที่สุด) ต้องถือ r.mu
+func (r *Registry) bindingLocked(topic Topic) (topicBinding, bool) {
+	if b, ok := r.topics[topic]; ok {
+		return b, true
+	}
+	for _, pattern := range r.patterns {
+		if Match(pattern, topic) {
+			return r.topics[pattern], true
+		}
+	}
+	return topicBinding{}, false
+}
+
+// Encode: [ความยาวชื่อ codec uint8][ชื่อ codec][ความยาวชื่อชนิด uvarint][ชื่อชนิด][body]
+func (r *Registry) Encode(topic Topic, data any) ([]byte, error) {
+	t := reflect.TypeOf(data)
+	name := typeName(t)
+
+	r.mu.RLock()
+	b, bound := r.bindingLocked(topic)
+	codec := b.codec
+	if codec == nil {
+		codec = r.byType[t]
+	}
+	if codec == nil {
+		codec = r.def
+	}
+	r.mu.RUnlock()
+
+	if bound && b.typ != nil && t != b.typ {
+		return nil, &EncodeError{Topic: topic, Codec: codec.Name(), Type: name,
+			Err: &TypeMismatchError{Topic: topic, Want: b.typ.String(), Got: fmt.Sprintf("%T", data)}}
+	}
+	body, err := codec.Marshal(data)
+	if err != nil {
+		return nil, &EncodeError{Topic: topic, Codec: codec.Name(), Type: name, Err: err}
+	}
+	out := make([]byte, 0, 1+len(codec.Name())+binary.MaxVarintLen64+len(name)+len(body))
+	out = append(out, byte(len(codec.Name())))
+	out = append(out, codec.Name()...)
+	out = binary.AppendUvarint(out, uint64(len(name)))
+	out = append(out, name...)
+	return append(out, body...), nil
+}
+
+// Decode ถอด payload ที่ได้จาก Encode
+func (r *Registry) Decode(topic Topic, b []byte) (any, error) {
+	codecName, name, body, ok := splitPayload(b)
+	if !ok {
+		return nil, &DecodeError{Topic: topic, Err: ErrMalformedPayload}
+	}
+
+	r.mu.RLock()
+	codec := r.codecs[codecName]
+	t := r.types[name]
+	if bnd, bound := r.bindingLocked(topic); bound && bnd.typ != nil {
+		t = bnd.typ
+	}
+	r.mu.RUnlock()
+
+	if codec == nil {
+		return nil, &DecodeError{Topic: topic, Codec: codecName, Type: name, Err: ErrUnknownCodec}
+	}
+	if t == nil {
+		var v any
+		if err := codec.Unmarshal(body, &v); err != nil {
+			return nil, &DecodeError{Topic: topic, Codec: codecName, Type: name, Err: err}
+		}
+		return v, nil
+	}
+	v := reflect.New(t)
+	if err := codec.Unmarshal(body, v.Interface()); err != nil {
+		return nil, &DecodeError{Topic: topic, Codec: codecName, Type: name, Err: err}
+	}
+	return v.Elem().Interface(), nil
+}
+
+func splitPayload(b []byte) (codec, typ string, body []byte, ok bool) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return "", "", nil, false
+	}
+	codec, b = string(b[1:1+int(b[0])]), b[1+int(b[0]):]
+	n, size := binary.Uvarint(b)
+	if size <= 0 || uint64(len(b)-size) < n {
+		return "", "", nil, false
+	}
+	typ, body = string(b[size:size+int(n)]), b[size+int(n):]
+	return codec, typ, body, true
+}
+
+// typeName ชื่อชนิดที่ไม่ซ้ำข้าม package เช่น "internal-pubsub/examples/subscriber/model.Order"
+func typeName(t reflect.Type) string {
+	switch {
+	case t == nil:
+		return ""
+	case t.Name() != "" && t.PkgPath() != "":
+		return t.PkgPath() + "." + t.Name()
+	default:
+		return t.String()
+	}
+}
+
+// EncodedEvent คือ Event ที่ Data ผ่าน PayloadCodec แล้ว
+// ใช้เป็นรูปกลางสำหรับ transport และที่เก็บข้อมูล (ตัวมันเอง encode ต่อด้วย gob หรือ json ได้)
+type EncodedEvent struct {
+	ID      string
+	Topic   Topic
+	Time    time.Time
+	Headers map[string]string
+	Data    []byte
+	Attempt int
+}
+
+// EncodeEvent แปลง Event เป็น EncodedEvent ด้วย c (nil = DefaultRegistry) error เป็น *EncodeError เสมอ
+func EncodeEvent(c PayloadCodec, ev Event) (EncodedEvent, error) {
+	if c == nil {
+		c = DefaultRegistry
+	}
+	data, err := c.Encode(ev.Topic, ev.Data)
+	if err != nil {
+		var ee *EncodeError
+		if !errors.As(err, &ee) {
+			err = &EncodeError{Topic: ev.Topic, Type: typeName(reflect.TypeOf(ev.Data)), Err: err}
+		}
+		return EncodedEvent{}, err
+	}
+	return EncodedEvent{ID: ev.ID, Topic: ev.Topic, Time: ev.Time, Headers: ev.Headers, Data: data, Attempt: ev.Attempt}, nil
+}
+
+// Decode แปลงกลับเป็น Event ด้วย c (nil = DefaultRegistry) error เป็น *DecodeError เสมอ
+// ถ้า decode ไม่ได้จะคืน Event ที่ไม่มี Data มาด้วยเพื่อใช้ log/นับได้
+func (e EncodedEvent) Decode(c PayloadCodec) (Event, error) {
+	if c == nil {
+		c = DefaultRegistry
+	}
+	ev := Event{ID: e.ID, Topic: e.Topic, Time: e.Time, Headers: e.Headers, Attempt: e.Attempt}
+	data, err := c.Decode(e.Topic, e.Data)
+	if err != nil {
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			err = &DecodeError{Topic: e.Topic, Err: err}
+		}
+		return ev, err
+	}
+	ev.Data = data
+	return ev, nil
+}
@@ -0,0 +1,129 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type codecOrder struct {
+	ID    string
+	Total int
+}
+
+func TestRegistryRoundTripsRegisteredTypes(t *testing.T) {
+	r := NewRegistry(nil)
+	r.RegisterType(codecOrder{}, JSONCodec)
+	r.RegisterTopic("audit.*", JSONCodec, nil)
+
+	b, err := r.Encode("order.created", codecOrder{ID: "o-1", Total: 42})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := r.Decode("order.created", b)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := (codecOrder{ID: "o-1", Total: 42}); got != want {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	// topic ที่ผูกกับ JSON แต่ไม่ได้ลงทะเบียนชนิด ได้ค่าตามที่ JSON ถอดเป็น any
+	b, err = r.Encode("audit.log", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatalf("encode audit: %v", err)
+	}
+	got, err = r.Decode("audit.log", b)
+	if err != nil {
+		t.Fatalf("decode audit: %v", err)
+	}
+	if want := map[string]any{"n": float64(1)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestRegistryTopicTypeMismatch(t *testing.T) {
+	r := NewRegistry(nil)
+	r.RegisterTopic("order.created", JSONCodec, codecOrder{})
+
+	_, err := r.Encode("order.created", "not an order")
+	var ee *EncodeError
+	if !errors.As(err, &ee) || !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("err = %v, want EncodeError wrapping ErrTypeMismatch", err)
+	}
+	if ee.Codec != "json" || ee.Topic != "order.created" {
+		t.Fatalf("unexpected error fields: %+v", ee)
+	}
+}
+
+func TestRegistryPicksMostSpecificTopicPattern(t *testing.T) {
+	// ลงทะเบียนกว้างก่อนและหลังตัวเจาะจง ผลต้องเหมือนกันทุกครั้ง
+	for _, order := range [][]Topic{{"order.#", "order.*", "order.item.*"}, {"order.item.*", "order.*", "order.#"}} {
+		r := NewRegistry(nil)
+		codecs := map[Topic]Codec{"order.#": GobCodec, "order.*": JSONCodec, "order.item.*": GobCodec}
+		for _, p := range order {
+			r.RegisterTopic(p, codecs[p], nil)
+		}
+		for topic, want := range map[Topic]string{"order.created": "json", "order.item.added": "gob", "order.a.b.c": "gob"} {
+			b, err := r.Encode(topic, 1)
+			if err != nil {
+				t.Fatalf("encode %s: %v", topic, err)
+			}
+			if got, _, _, _ := splitPayload(b); got != want {
+				t.Fatalf("order %v: %s encoded with %s, want %s", order, topic, got, want)
+			}
+		}
+	}
+}
+
+type gobOnlyOrder struct{ ID string }
+
+func TestRegisterTypeRegistersWithGob(t *testing.T) {
+	r := NewRegistry(nil)
+	r.RegisterType(gobOnlyOrder{}, nil)
+
+	b, err := r.Encode("order.created", gobOnlyOrder{ID: "o-1"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := r.Decode("order.created", b)
+	if err != nil || got != (gobOnlyOrder{ID: "o-1"}) {
+		t.Fatalf("got %#v err %v", got, err)
+	}
+}
+
+func TestEncodedEventDecodeErrors(t *testing.T) {
+	enc, err := EncodeEvent(nil, Event{ID: "e-1", Topic: "order.created", Data: "hello"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	ev, err := enc.Decode(nil)
+	if err != nil || ev.Data != "hello" || ev.ID != "e-1" {
+		t.Fatalf("decode = %+v, %v", ev, err)
+	}
+
+	// registry ที่ไม่รู้จัก codec ในหัว payload
+	custom := NewRegistry(nil)
+	custom.RegisterTopic("order.created", namedCodec{"upper"}, nil)
+	enc, err = EncodeEvent(custom, Event{ID: "e-2", Topic: "order.created", Data: "hello"})
+	if err != nil {
+		t.Fatalf("encode custom: %v", err)
+	}
+	ev, err = enc.Decode(NewRegistry(nil))
+	if !errors.Is(err, ErrUnknownCodec) || ev.ID != "e-2" {
+		t.Fatalf("decode = %+v, %v; want ErrUnknownCodec", ev, err)
+	}
+
+	_, err = EncodedEvent{Topic: "order.created", Data: []byte{9}}.Decode(nil)
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrMalformedPayload) {
+		t.Fatalf("err = %v, want DecodeError wrapping ErrMalformedPayload", err)
+	}
+}
+
+// namedCodec ใช้ JSON แต่ชื่อต่างออกไป
+type namedCodec struct{ name string }
+
+func (c namedCodec) Name() string                     { return c.name }
+func (namedCodec) Marshal(v any) ([]byte, error)      { return JSONCodec.Marshal(v) }
+func (namedCodec) Unmarshal(data []byte, v any) error { return JSONCodec.Unmarshal(data, v) }
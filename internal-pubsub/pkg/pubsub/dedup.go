@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderIdempotencyKey key ที่ผู้ publish กำหนดเพื่อให้ bus ตัด event ซ้ำ (ดู Options.Dedup)
+const HeaderIdempotencyKey = "idempotency-key"
+
+// defaultDedupMaxKeys เพดานจำนวน key เมื่อไม่ได้กำหนด DedupOptions.MaxKeys
+const defaultDedupMaxKeys = 10000
+
+// WithIdempotencyKey กำหนด idempotency key ให้ Publish ที่ใช้ ctx นี้
+// key ถูกเทียบแยกตาม topic จึงใช้ key เดียวกันกับหลาย topic ได้ (เช่น request id ของ HTTP)
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return WithHeaders(ctx, map[string]string{HeaderIdempotencyKey: key})
+}
+
+// DedupOptions เปิดการตัด event ซ้ำฝั่ง publisher
+// event ที่มี HeaderIdempotencyKey ซ้ำกับที่เคย publish ไปใน topic เดียวกันภายใน Window
+// จะไม่ถูกส่ง (Publish คืน nil) และถูกนับใน Counters.Suppressed ของ topic
+// ระหว่างที่ Publish ครั้งแรกยังไม่จบ ตัวซ้ำจะได้ ErrDuplicateInFlight เพื่อให้ retry
+// (ถ้าครั้งแรกล้มเหลว key จะถูกลืมและ retry นั้นจะถูกส่งจริง)
+type DedupOptions struct {
+	Window  time.Duration // ระยะเวลาที่จำ key (0 = ปิด)
+	MaxKeys int           // จำนวน key สูงสุดที่จำ เกินแล้วลืมตัวเก่าสุดก่อน (<= 0 ใช้ 10000)
+}
+
+// DedupStats สถานะของตัวตัด event ซ้ำ
+type DedupStats struct {
+	Keys       int    // key ที่จำอยู่ตอนนี้
+	Suppressed uint64 // event ที่ถูกตัดทั้งหมด
+	Evicted    uint64 // key ที่ถูกลืมก่อนหมด Window เพราะเกิน MaxKeys
+}
+
+type dedupKey struct {
+	topic Topic
+	key   string
+}
+
+type dedupEntry struct {
+	k       dedupKey
+	expires time.Time
+}
+
+// dedupStore จำ key ตามลำดับเวลา (Window คงที่ ลำดับหมดอายุจึงตรงกับลำดับที่เพิ่ม)
+type dedupStore struct {
+	window  time.Duration
+	maxKeys int
+	clock   Clock
+
+	mu       sync.Mutex
+	seen     map[dedupKey]time.Time // key -> เวลาหมดอายุ
+	inflight map[dedupKey]struct{}  // key ที่ Publish ครั้งแรกยังไม่จบ
+	order    []dedupEntry           // FIFO ตามเวลาหมดอายุ
+
+	suppressed atomic.Uint64
+	evicted    atomic.Uint64
+}
+
+// newDedupStore คืน nil เมื่อไม่ได้เปิดใช้
+func newDedupStore(opts DedupOptions, clock Clock) *dedupStore {
+	if opts.Window <= 0 {
+		return nil
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = defaultDedupMaxKeys
+	}
+	return &dedupStore{
+		window:   opts.Window,
+		maxKeys:  opts.MaxKeys,
+		clock:    clock,
+		seen:     make(map[dedupKey]time.Time),
+		inflight: make(map[dedupKey]struct{}),
+	}
+}
+
+// admit คืน false ถ้า event เป็นตัวซ้ำ และคืน ErrDuplicateInFlight ถ้าตัวแรกยังส่งไม่จบ
+// ถ้าไม่ซ้ำจะจำ key ไว้ และคืน settle ที่ต้องเรียกเมื่อ publish จบ (sent = false จะลืม key ให้ retry ได้)
+func (d *dedupStore) admit(ev Event) (ok bool, settle func(sent bool), err error) {
+	key := ev.Headers[HeaderIdempotencyKey]
+	if d == nil || key == "" {
+		return true, func(bool) {}, nil
+	}
+	k := dedupKey{topic: ev.Topic, key: key}
+	now := d.clock.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, busy := d.inflight[k]; busy {
+		return false, nil, ErrDuplicateInFlight
+	}
+	d.expireLocked(now)
+	if _, dup := d.seen[k]; dup {
+		d.suppressed.Add(1)
+		return false, nil, nil
+	}
+	expires := now.Add(d.window)
+	d.seen[k] = expires
+	d.inflight[k] = struct{}{}
+	d.order = append(d.order, dedupEntry{k: k, expires: expires})
+	for len(d.seen) > d.maxKeys {
+		d.popLocked()
+		d.evicted.Add(1)
+	}
+	return true, func(sent bool) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.inflight, k)
+		if !sent && d.seen[k].Equal(expires) {
+			delete(d.seen, k) // entry ใน order จะถูกข้ามตอน pop
+		}
+	}, nil
+}
+
+func (d *dedupStore) expireLocked(now time.Time) {
+	for len(d.order) > 0 && !d.order[0].expires.After(now) {
+		d.popLocked()
+	}
+}
+
+// popLocked ลืม key ที่เก่าที่สุดที่ยังจำอยู่
+func (d *dedupStore) popLocked() {
+	for len(d.order) > 0 {
+		e := d.order[0]
+		d.order[0] = dedupEntry{}
+		d.order = d.order[1:]
+		if exp, ok := d.seen[e.k]; ok && exp.Equal(e.expires) {
+			delete(d.seen, e.k)
+			return
+		}
+	}
+}
+
+func (d *dedupStore) stats() DedupStats {
+	if d == nil {
+		return DedupStats{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DedupStats{Keys: len(d.seen), Suppressed: d.suppressed.Load(), Evicted: d.evicted.Load()}
+}
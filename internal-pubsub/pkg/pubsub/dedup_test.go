@@ -0,0 +1,107 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedupSuppressesRepeatedKeyWithinWindow(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock, Dedup: DedupOptions{Window: time.Minute}})
+	defer closeNow(bus)
+
+	orders := bus.Subscribe("order.created", 8)
+	audit := bus.Subscribe("order.audited", 8)
+	ctx := WithIdempotencyKey(context.Background(), "req-1")
+
+	rp := bus.(ReportingPublisher)
+	for range 2 {
+		_ = bus.Publish(ctx, "order.created", "ORD-1")
+	}
+	if rep, _ := rp.PublishWithReport(ctx, "order.created", "ORD-1"); !rep.Suppressed {
+		t.Fatalf("expected duplicate to be suppressed, got %+v", rep)
+	}
+	_ = bus.Publish(ctx, "order.audited", "ORD-1") // คนละ topic ไม่นับว่าซ้ำ
+	_ = bus.Publish(context.Background(), "order.created", "ORD-2")
+
+	clock.Advance(time.Minute)
+	_ = bus.Publish(ctx, "order.created", "ORD-1")
+
+	if got := len(orders.C()); got != 3 {
+		t.Fatalf("order.created got %d events, want 3", got)
+	}
+	if got := len(audit.C()); got != 1 {
+		t.Fatalf("order.audited got %d events, want 1", got)
+	}
+	st := bus.(StatsReporter).Stats()
+	if st.Topics["order.created"].Suppressed != 2 || st.Dedup.Suppressed != 2 || st.Dedup.Keys != 1 {
+		t.Fatalf("unexpected stats topics=%+v dedup=%+v", st.Topics, st.Dedup)
+	}
+}
+
+func TestDedupBoundedAndReleasedOnFailure(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock, Dedup: DedupOptions{Window: time.Hour, MaxKeys: 2}})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1)
+	_ = bus.Publish(WithIdempotencyKey(context.Background(), "a"), "order.created", 1)
+
+	// chan เต็ม: publish ล้มเหลวเพราะ ctx จึงต้องลืม key ให้ retry ได้
+	failed, cancel := context.WithCancel(WithIdempotencyKey(context.Background(), "b"))
+	cancel()
+	if err := bus.Publish(failed, "order.created", 2); err == nil {
+		t.Fatal("expected publish to fail on full channel")
+	}
+	<-sub.C()
+	if err := bus.Publish(WithIdempotencyKey(context.Background(), "b"), "order.created", 2); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	<-sub.C()
+
+	_ = bus.Publish(WithIdempotencyKey(context.Background(), "c"), "order.created", 3)
+	<-sub.C()
+	st := bus.(StatsReporter).Stats().Dedup
+	if st.Keys != 2 || st.Evicted != 1 || st.Suppressed != 0 {
+		t.Fatalf("unexpected dedup stats %+v", st)
+	}
+}
+
+func TestDedupDuplicateWhileFirstInFlight(t *testing.T) {
+	bus := New(Options{Dedup: DedupOptions{Window: time.Hour}})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1)
+	_ = bus.Publish(context.Background(), "order.created", "filler")
+
+	// ตัวแรกบล็อกเพราะ chan เต็ม แล้วล้มเหลวเมื่อ ctx ถูกยกเลิก
+	first, cancel := context.WithCancel(WithIdempotencyKey(context.Background(), "req-1"))
+	failed := make(chan error, 1)
+	go func() { failed <- bus.Publish(first, "order.created", "ORD-1") }()
+	deadline := time.Now().Add(time.Second)
+	for bus.(Inspector).Stats().Subscriptions[0].BlockedPublishers == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("first publish never blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx := WithIdempotencyKey(context.Background(), "req-1")
+	if err := bus.Publish(ctx, "order.created", "ORD-1"); !errors.Is(err, ErrDuplicateInFlight) {
+		t.Fatalf("duplicate while in flight: err = %v, want ErrDuplicateInFlight", err)
+	}
+	cancel()
+	if err := <-failed; err == nil {
+		t.Fatal("expected first publish to fail")
+	}
+
+	<-sub.C()
+	if err := bus.Publish(ctx, "order.created", "ORD-1"); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+	if ev := <-sub.C(); ev.Data != "ORD-1" {
+		t.Fatalf("got %v, want ORD-1", ev.Data)
+	}
+}
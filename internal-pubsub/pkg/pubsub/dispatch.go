@@ -0,0 +1,407 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DeliveryStatus ผลการส่ง event ให้ subscription หนึ่งตัว
+type DeliveryStatus int
+
+const (
+	// StatusDelivered: เข้า chan ของผู้รับทันที
+	StatusDelivered DeliveryStatus = iota
+	// StatusQueued: chan เต็ม แต่เข้าคิวของ dispatcher ได้ (ไม่บล็อก publisher)
+	StatusQueued
+	// StatusWaited: ทั้ง chan และคิวเต็ม publisher ต้องรอจนมีที่ว่าง (back-pressure)
+	StatusWaited
+	// StatusDropped: ทิ้งเพราะเต็มในโหมด DeliveryDrop
+	StatusDropped
+	// StatusTimedOut: ทิ้งเพราะรอเกิน timeout ในโหมด DeliveryTimeout
+	StatusTimedOut
+	// StatusFailed: ctx ถูกยกเลิก หรือ subscription/bus ถูกปิดก่อนส่งได้
+	StatusFailed
+	// StatusSpilled: chan เต็ม จึงเขียนลงคิวบนดิสก์ (DeliverySpill) ไม่บล็อก publisher
+	StatusSpilled
+)
+
+// SubscriberDelivery ผลการส่งให้ subscription ที่ไม่ได้รับทันที
+type SubscriberDelivery struct {
+	SubscriptionID string
+	Topic          Topic // topic หรือ pattern ที่ใช้ Subscribe
+	Group          string
+	Status         DeliveryStatus
+	Err            error
+}
+
+// DeliveryReport สรุปผลการ Publish หนึ่งครั้ง
+type DeliveryReport struct {
+	EventID       string
+	Topic         Topic
+	Delivered     int                  // จำนวน subscription ที่ได้รับ event (รวม Queued/Waited)
+	Suppressed    bool                 // event ซ้ำตาม idempotency key จึงไม่ถูกส่ง (ดู Options.Dedup)
+	Backpressured []SubscriberDelivery // subscription ที่รับทันทีไม่ได้
+}
+
+// ReportingPublisher implement โดย Bus ที่บอกได้ว่า subscriber ตัวไหนทำให้ Publish ต้องรอหรือทิ้ง event
+type ReportingPublisher interface {
+	PublishWithReport(ctx context.Context, topic Topic, data any) (DeliveryReport, error)
+}
+
+// PublishWithReport เหมือน Publish แต่คืนรายงานการส่งรายผู้รับ
+// subscription ที่เต็มจะไม่ขวางการส่งให้ตัวอื่น: ทุกตัวได้ลองส่งแบบไม่บล็อกก่อน
+// แล้วจึงรอเฉพาะตัวที่เต็มพร้อมกัน (ตามโหมดของแต่ละตัว)
+func (b *memoryBus) PublishWithReport(ctx context.Context, topic Topic, data any) (DeliveryReport, error) {
+	return b.publish(ctx, NewEventAt(ctx, topic, data, b.opts.Clock.Now()))
+}
+
+// publish ส่ง event ที่สร้างไว้แล้วให้ผู้รับทุกตัวที่ match กับ ev.Topic
+func (b *memoryBus) publish(ctx context.Context, ev Event) (report DeliveryReport, err error) {
+	topic := ev.Topic
+	ev.Attempt = 0 // เป็นของการส่งให้ผู้รับแต่ละตัว (โหมด ack) ไม่ใช่ของผู้ publish
+	report = DeliveryReport{EventID: ev.ID, Topic: topic}
+
+	b.mu.RLock()
+	if b.sealed {
+		b.mu.RUnlock()
+		return report, ErrClosed
+	}
+	if b.closed {
+		if _, causal := EventFromContext(ctx); !causal {
+			b.mu.RUnlock()
+			return report, ErrClosed
+		}
+	}
+	admitted, settle, err := b.dedup.admit(ev)
+	if err != nil {
+		b.mu.RUnlock()
+		return report, err
+	}
+	if !admitted {
+		b.mu.RUnlock()
+		b.topicCounters(topic).suppressed.Add(1)
+		report.Suppressed = true
+		return report, nil
+	}
+	defer func() { settle(err == nil || report.Delivered > 0) }()
+	b.inflight.Add(1)
+	defer func() {
+		b.inflight.Add(-1)
+		b.settle()
+	}()
+	// เก็บ retained และ snapshot ภายใต้ lock เดียวกัน ผู้สมัครใหม่จึงได้ event นี้
+	// ทางใดทางหนึ่งเท่านั้น (จาก retained หรือจาก live) ไม่ซ้ำและไม่หาย
+	b.retained.add(ev)
+	// snapshot subscribers เพื่อหลีกเลี่ยง hold lock นานเกินไปตอนส่ง
+	// เลือกสมาชิกของแต่ละ group ที่นี่ที่เดียว event หนึ่งจึงขยับลำดับ round-robin เพียงครั้งเดียว
+	subs, groups := b.matchLocked(topic)
+	for _, g := range groups {
+		if s := g.pick(); s != nil {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	// รอบแรก: ส่งแบบไม่บล็อกให้ทุกตัว
+	results := make([]SubscriberDelivery, len(subs))
+	var full []int
+	for i, s := range subs {
+		results[i] = SubscriberDelivery{SubscriptionID: s.id, Topic: s.topic, Group: s.group}
+		st, ok := s.offer(ev)
+		if !ok {
+			full = append(full, i)
+			continue
+		}
+		results[i].Status = st
+	}
+
+	// รอบสอง: รอเฉพาะตัวที่เต็ม แยก goroutine กันเพื่อไม่ให้ตัวหนึ่งขวางอีกตัว
+	if len(full) == 1 {
+		i := full[0]
+		results[i].Status, results[i].Err = subs[i].wait(ctx, ev)
+	} else if len(full) > 1 {
+		var wg sync.WaitGroup
+		for _, i := range full {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i].Status, results[i].Err = subs[i].wait(ctx, ev)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	for _, r := range results {
+		switch r.Status {
+		case StatusDelivered:
+			report.Delivered++
+			continue
+		case StatusQueued, StatusWaited, StatusSpilled:
+			report.Delivered++
+		}
+		report.Backpressured = append(report.Backpressured, r)
+		if r.Err != nil && err == nil {
+			err = r.Err
+		}
+	}
+	return report, err
+}
+
+// offer ส่งแบบไม่บล็อก: เข้า chan ถ้าคิวว่างและ chan ไม่เต็ม ไม่งั้นเข้าคิวถ้ายังมีที่
+// คืน ok == false เมื่อเต็มทั้งคู่ (ยกเว้น DeliveryDrop ที่ทิ้งทันที)
+func (s *memSub) offer(ev Event) (DeliveryStatus, bool) {
+	s.reserve()
+	st, ok := s.tryEnqueue(ev)
+	switch {
+	case ok:
+		s.bus.record(s, ev, outcomeDelivered)
+		return st, true
+	case st == StatusFailed:
+		s.release()
+		return st, true
+	case s.mode == DeliveryDrop:
+		s.release()
+		s.bus.record(s, ev, outcomeDropped)
+		return StatusDropped, true
+	case s.spill != nil:
+		return s.trySpill(ev)
+	}
+	s.release()
+	return 0, false
+}
+
+// wait รอจนส่งได้ตามโหมด (DeliveryBlock/DeliveryTimeout)
+func (s *memSub) wait(ctx context.Context, ev Event) (DeliveryStatus, error) {
+	s.waiting.Add(1)
+	defer s.waiting.Add(-1)
+
+	var expired <-chan time.Time
+	if s.mode == DeliveryTimeout {
+		timeout := s.timeout
+		if timeout <= 0 {
+			timeout = 100 * time.Millisecond
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	s.reserve()
+	var (
+		st  DeliveryStatus
+		err error
+	)
+	if s.queueCap > 0 {
+		st, err = s.waitQueue(ctx, ev, expired)
+	} else {
+		st, err = s.waitChan(ctx, ev, expired)
+	}
+	switch st {
+	case StatusWaited:
+		s.bus.record(s, ev, outcomeDelivered)
+		return st, nil
+	case StatusTimedOut:
+		s.bus.record(s, ev, outcomeTimedOut)
+	}
+	s.release()
+	return st, err
+}
+
+// waitChan ส่งเข้า chan แบบบล็อก (ไม่มีคิว)
+func (s *memSub) waitChan(ctx context.Context, ev Event, expired <-chan time.Time) (DeliveryStatus, error) {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+	select {
+	case <-s.done:
+		return StatusFailed, ErrClosed
+	default:
+	}
+	select {
+	case s.ch <- ev:
+		s.received()
+		return StatusWaited, nil
+	case <-expired:
+		return StatusTimedOut, nil
+	case <-s.done:
+		return StatusFailed, ErrClosed
+	case <-s.bus.abort:
+		s.bus.abandoned.Add(1)
+		return StatusFailed, ErrClosed
+	case <-ctx.Done():
+		return StatusFailed, ctx.Err()
+	}
+}
+
+// waitQueue รอจนคิวมีที่ว่างแล้วเข้าคิว
+func (s *memSub) waitQueue(ctx context.Context, ev Event, expired <-chan time.Time) (DeliveryStatus, error) {
+	for {
+		s.mu.Lock()
+		space := s.space
+		s.mu.Unlock()
+		if st, ok := s.tryEnqueue(ev); ok {
+			return StatusWaited, nil
+		} else if st == StatusFailed {
+			return st, ErrClosed
+		}
+		select {
+		case <-space:
+		case <-expired:
+			return StatusTimedOut, nil
+		case <-s.done:
+			return StatusFailed, ErrClosed
+		case <-s.bus.abort:
+			s.bus.abandoned.Add(1)
+			return StatusFailed, ErrClosed
+		case <-ctx.Done():
+			return StatusFailed, ctx.Err()
+		}
+	}
+}
+
+// tryEnqueue ลองส่งแบบไม่บล็อก คืน StatusFailed เมื่อ subscription ปิดแล้ว
+func (s *memSub) tryEnqueue(ev Event) (DeliveryStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return StatusFailed, false
+	}
+	// มีของค้างบนดิสก์ ต้องต่อท้ายบนดิสก์เพื่อรักษาลำดับ
+	if s.spill.len() > 0 {
+		return StatusWaited, false
+	}
+	// มีของค้างในคิว ต้องต่อท้ายคิวเพื่อรักษาลำดับ
+	if len(s.queue) == 0 {
+		sent := ev
+		if s.ack != nil {
+			sent = s.beginLocked(ev)
+		}
+		select {
+		case s.ch <- sent:
+			s.received()
+			if s.ack != nil {
+				s.startLocked(sent)
+			}
+			return StatusDelivered, true
+		default:
+			if s.ack != nil {
+				s.cancelLocked(sent)
+			}
+		}
+	}
+	if len(s.queue) < s.queueCap {
+		s.queue = append(s.queue, ev)
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+		return StatusQueued, true
+	}
+	return StatusWaited, false
+}
+
+// trySpill เขียน event ลงคิวบนดิสก์ (pending ถูก reserve ไว้แล้วโดย offer)
+func (s *memSub) trySpill(ev Event) (DeliveryStatus, bool) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		s.release()
+		return StatusFailed, true
+	}
+	err := s.spill.push(ev)
+	if err == nil {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.release()
+		s.bus.recordSpillDrop(s, ev, err)
+		return StatusDropped, true
+	}
+	s.bus.record(s, ev, outcomeDelivered)
+	return StatusSpilled, true
+}
+
+// dispatch ย้าย event จากคิว (แล้วจึงจากดิสก์) เข้า chan ตามลำดับ ผู้รับที่ช้าจึงกระทบแค่คิวของตัวเอง
+func (s *memSub) dispatch() {
+	defer close(s.dispatcherDone)
+	for {
+		var (
+			ev        Event
+			spillSize int64
+		)
+		s.mu.Lock()
+		switch {
+		case len(s.queue) > 0:
+			ev = s.queue[0]
+		case s.spill.len() > 0:
+			var err error
+			ev, spillSize, err = s.spill.peek()
+			if err != nil {
+				if spillSize > 0 {
+					s.spill.pop(spillSize)
+				}
+				s.mu.Unlock()
+				s.release()
+				s.bus.recordSpillDrop(s, ev, err)
+				continue
+			}
+		default:
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		if s.ack != nil {
+			ev = s.beginLocked(ev)
+		}
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- ev:
+			s.received()
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		if s.ack != nil {
+			s.startLocked(ev)
+		}
+		if spillSize > 0 {
+			s.spill.pop(spillSize)
+		} else {
+			s.queue = s.queue[1:]
+			close(s.space)
+			s.space = make(chan struct{})
+		}
+		s.mu.Unlock()
+	}
+}
+
+// reserve/release นับ pending ไว้ก่อนส่ง เพื่อไม่ให้ผู้รับ MarkDone ก่อนเรานับ
+func (s *memSub) reserve() {
+	if s.tracked {
+		s.pending.Add(1)
+	}
+}
+
+func (s *memSub) release() {
+	if s.tracked {
+		s.pending.Add(-1)
+	}
+}
+
+// load งานที่ค้างใน chan คิว และดิสก์
+func (s *memSub) load() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ch) + len(s.queue) + s.spill.len()
+}
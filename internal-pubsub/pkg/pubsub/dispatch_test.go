@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSlowSubscriberDoesNotStallOthers(t *testing.T) {
+	bus := New(DefaultOptions()) // DeliveryBlock
+	defer closeNow(bus)
+
+	slow := bus.Subscribe("order.created", 1, WithDispatchQueue(8))
+	fast := bus.Subscribe("order.created", 8)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := range 5 {
+		if err := bus.Publish(ctx, "order.created", i); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+	if got := len(fast.C()); got != 5 {
+		t.Fatalf("fast subscriber got %d events, want 5", got)
+	}
+	for i := range 5 {
+		select {
+		case ev := <-slow.C():
+			if ev.Data != i {
+				t.Fatalf("slow subscriber out of order: got %v want %d", ev.Data, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("slow subscriber missing event %d", i)
+		}
+	}
+}
+
+func TestPublishWithReportListsBackpressured(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	bus.Subscribe("order.created", 1, WithDeliveryMode(DeliveryDrop))
+	queued := bus.Subscribe("order.*", 1, WithDispatchQueue(4))
+	bus.Subscribe("#", 4)
+
+	rp := bus.(ReportingPublisher)
+	first, err := rp.PublishWithReport(context.Background(), "order.created", 1)
+	if err != nil || first.Delivered != 3 || len(first.Backpressured) != 0 {
+		t.Fatalf("first report %+v err=%v", first, err)
+	}
+
+	second, err := rp.PublishWithReport(context.Background(), "order.created", 2)
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if second.Delivered != 2 || len(second.Backpressured) != 2 {
+		t.Fatalf("second report %+v", second)
+	}
+	status := map[Topic]DeliveryStatus{}
+	for _, d := range second.Backpressured {
+		status[d.Topic] = d.Status
+	}
+	if status["order.created"] != StatusDropped || status["order.*"] != StatusQueued {
+		t.Fatalf("unexpected statuses %+v", second.Backpressured)
+	}
+
+	var st SubscriptionStats
+	for _, ss := range bus.(Inspector).Stats().Subscriptions {
+		if ss.ID == queued.(*memSub).id {
+			st = ss
+		}
+	}
+	if st.QueueCapacity != 4 || st.Length+st.Queued != 2 {
+		t.Fatalf("unexpected queue stats %+v", st)
+	}
+}
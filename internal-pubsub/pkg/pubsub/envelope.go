@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"time"
+)
+
+const (
+	// HeaderCorrelationID ไอดีของทั้งสายงาน (เหมือนกันตั้งแต่ event แรกจนถึงตัวสุดท้าย)
+	HeaderCorrelationID = "correlation-id"
+	// HeaderCausationID ไอดีของ event ที่ทำให้เกิด event นี้โดยตรง
+	HeaderCausationID = "causation-id"
+)
+
+type ctxKey int
+
+const (
+	ctxKeyEvent ctxKey = iota
+	ctxKeyHeaders
+)
+
+// ContextWithEvent ผูก event ที่กำลังประมวลผลไว้กับ ctx
+// Publish ที่ใช้ ctx นี้จะได้ correlation/causation id ต่อจาก event นี้อัตโนมัติ
+func ContextWithEvent(ctx context.Context, ev Event) context.Context {
+	return context.WithValue(ctx, ctxKeyEvent, ev)
+}
+
+// EventFromContext คืน event ที่ผูกไว้ด้วย ContextWithEvent
+func EventFromContext(ctx context.Context) (Event, bool) {
+	ev, ok := ctx.Value(ctxKeyEvent).(Event)
+	return ev, ok
+}
+
+// WithHeaders เพิ่ม header ที่จะติดไปกับทุก Publish ที่ใช้ ctx นี้ (ค่าใหม่ทับค่าเดิม)
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := maps.Clone(headersFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(headers))
+	}
+	maps.Copy(merged, headers)
+	return context.WithValue(ctx, ctxKeyHeaders, merged)
+}
+
+// WithCorrelationID กำหนด correlation id ให้ Publish ที่ใช้ ctx นี้ (เช่น request id จาก HTTP)
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return WithHeaders(ctx, map[string]string{HeaderCorrelationID: id})
+}
+
+func headersFromContext(ctx context.Context) map[string]string {
+	h, _ := ctx.Value(ctxKeyHeaders).(map[string]string)
+	return h
+}
+
+// NewEvent สร้าง envelope พร้อม id, เวลา และ header ที่สืบทอดจาก ctx
+// implementation ของ Bus ควรใช้ฟังก์ชันนี้ตอน Publish เพื่อให้ metadata เหมือนกันทุกตัว
+// Bus ที่มี Clock ของตัวเองใช้ NewEventAt แทน
+func NewEvent(ctx context.Context, topic Topic, data any) Event {
+	return NewEventAt(ctx, topic, data, time.Now())
+}
+
+// NewEventAt เหมือน NewEvent แต่ใช้ at เป็น Event.Time (เช่นเวลาจาก Options.Clock)
+func NewEventAt(ctx context.Context, topic Topic, data any, at time.Time) Event {
+	ev := Event{
+		ID:      newID(),
+		Topic:   topic,
+		Data:    data,
+		Time:    at,
+		Headers: make(map[string]string),
+	}
+	if parent, ok := EventFromContext(ctx); ok {
+		ev.Headers[HeaderCausationID] = parent.ID
+		ev.Headers[HeaderCorrelationID] = parent.CorrelationID()
+	}
+	maps.Copy(ev.Headers, headersFromContext(ctx))
+	if ev.Headers[HeaderCorrelationID] == "" {
+		// event ต้นสายใช้ id ของตัวเองเป็น correlation id
+		ev.Headers[HeaderCorrelationID] = ev.ID
+	}
+	return ev
+}
+
+// CorrelationID คืน correlation id ของ event (ถ้าไม่มีใช้ ID ของตัวเอง)
+func (e Event) CorrelationID() string {
+	if id := e.Headers[HeaderCorrelationID]; id != "" {
+		return id
+	}
+	return e.ID
+}
+
+// CausationID คืน id ของ event ที่เป็นต้นเหตุ (ว่างถ้าเป็น event ต้นสาย)
+func (e Event) CausationID() string {
+	return e.Headers[HeaderCausationID]
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewEventPropagatesCorrelation(t *testing.T) {
+	root := NewEvent(context.Background(), "order.created", "ORD-1")
+	if root.ID == "" || root.Time.IsZero() {
+		t.Fatalf("expected id and time, got %+v", root)
+	}
+	if root.CorrelationID() != root.ID || root.CausationID() != "" {
+		t.Fatalf("root event should correlate to itself, got %+v", root.Headers)
+	}
+
+	ctx := WithHeaders(ContextWithEvent(context.Background(), root), map[string]string{"tenant": "acme"})
+	child := NewEvent(ctx, "invoice.generated", "INV-1")
+	if child.CorrelationID() != root.ID {
+		t.Errorf("correlation = %q, want %q", child.CorrelationID(), root.ID)
+	}
+	if child.CausationID() != root.ID {
+		t.Errorf("causation = %q, want %q", child.CausationID(), root.ID)
+	}
+	if child.Headers["tenant"] != "acme" {
+		t.Errorf("expected tenant header, got %+v", child.Headers)
+	}
+
+	explicit := NewEvent(WithCorrelationID(context.Background(), "req-42"), "order.created", nil)
+	if explicit.CorrelationID() != "req-42" {
+		t.Errorf("correlation = %q, want req-42", explicit.CorrelationID())
+	}
+}
+
+func TestBusStampsEventTimeFromClock(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1)
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	if ev := <-sub.C(); !ev.Time.Equal(clock.Now()) {
+		t.Fatalf("event time = %v, want clock time %v", ev.Time, clock.Now())
+	}
+}
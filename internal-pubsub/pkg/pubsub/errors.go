@@ -1,7 +1,86 @@
 package pubsub
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrClosed = errors.New("pubsub: bus is closed")
+	ErrClosed       = errors.New("pubsub: bus is closed")
+	ErrTypeMismatch = errors.New("pubsub: payload type mismatch")
+	ErrNoResponders = errors.New("pubsub: no responders")
+
+	ErrScheduleCanceled = errors.New("pubsub: scheduled publish canceled")
+
+	// ErrDuplicateInFlight Publish ที่มี idempotency key เดียวกันยังส่งไม่จบ ให้ retry ภายหลัง
+	ErrDuplicateInFlight = errors.New("pubsub: duplicate publish still in flight")
+
+	ErrUnknownCodec     = errors.New("pubsub: unknown codec")
+	ErrMalformedPayload = errors.New("pubsub: malformed payload")
 )
+
+// TypeMismatchError บอกรายละเอียดเมื่อ payload ของ event ไม่ใช่ชนิดที่คาดไว้
+// ใช้ errors.Is(err, ErrTypeMismatch) เพื่อตรวจได้
+type TypeMismatchError struct {
+	Topic Topic
+	Want  string
+	Got   string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("pubsub: payload type mismatch topic=%s want=%s got=%s", e.Topic, e.Want, e.Got)
+}
+
+func (e *TypeMismatchError) Is(target error) bool { return target == ErrTypeMismatch }
+
+// DrainError คืนจาก Close เมื่อ ctx หมดเวลาก่อนประมวลผล event ที่ค้างอยู่ครบ
+// ใช้ errors.Is(err, context.DeadlineExceeded) ได้
+type DrainError struct {
+	Abandoned int // จำนวน event ที่ยังไม่ถูกประมวลผลเมื่อปิด
+	Err       error
+}
+
+func (e *DrainError) Error() string {
+	return fmt.Sprintf("pubsub: close before drain completed abandoned=%d: %v", e.Abandoned, e.Err)
+}
+
+func (e *DrainError) Unwrap() error { return e.Err }
+
+// ResponderError คืนจาก Request เมื่อผู้ตอบประมวลผลคำขอไม่สำเร็จ
+type ResponderError struct {
+	Topic   Topic
+	Message string
+}
+
+func (e *ResponderError) Error() string {
+	return fmt.Sprintf("pubsub: responder failed topic=%s: %s", e.Topic, e.Message)
+}
+
+// EncodeError คืนเมื่อแปลง payload เป็น bytes ไม่ได้ (เช่นชนิดไม่ตรงกับที่ลงทะเบียนไว้กับ topic)
+type EncodeError struct {
+	Topic Topic
+	Codec string
+	Type  string
+	Err   error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("pubsub: encode topic=%s codec=%s type=%s: %v", e.Topic, e.Codec, e.Type, e.Err)
+}
+
+func (e *EncodeError) Unwrap() error { return e.Err }
+
+// DecodeError คืนเมื่อแปลง bytes กลับเป็น payload ไม่ได้
+// ใช้ errors.Is(err, ErrUnknownCodec) หรือ errors.Is(err, ErrMalformedPayload) แยกสาเหตุได้
+type DecodeError struct {
+	Topic Topic
+	Codec string
+	Type  string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("pubsub: decode topic=%s codec=%s type=%s: %v", e.Topic, e.Codec, e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
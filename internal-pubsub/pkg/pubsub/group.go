@@ -0,0 +1,62 @@
+package pubsub
+
+import "sync/atomic"
+
+// GroupDispatch กลยุทธ์เลือกสมาชิกภายใน consumer group
+type GroupDispatch int
+
+const (
+	// DispatchRoundRobin: วนส่งให้สมาชิกทีละคนตามลำดับ
+	DispatchRoundRobin GroupDispatch = iota
+	// DispatchLeastLoaded: ส่งให้สมาชิกที่มีงานค้างน้อยที่สุด
+	DispatchLeastLoaded
+)
+
+// groupKey ระบุ group จาก topic (หรือ pattern) ที่ใช้ Subscribe และชื่อ group
+type groupKey struct {
+	topic Topic
+	name  string
+}
+
+// memGroup สมาชิกของ consumer group หนึ่ง แต่ละ event ไปถึงสมาชิกเพียงคนเดียว
+type memGroup struct {
+	dispatch GroupDispatch // กำหนดโดยสมาชิกคนแรก
+	members  []*memSub     // ลำดับคงที่ เพื่อให้ round-robin วนได้ถูกต้อง
+	next     atomic.Uint64
+}
+
+// pick เลือกสมาชิกที่จะได้รับ event ถัดไป เรียกจาก publish เท่านั้น (ภายใต้ read lock)
+// เพราะ round-robin ขยับ cursor ทุกครั้งที่เรียก
+func (g *memGroup) pick() *memSub {
+	if len(g.members) == 0 {
+		return nil
+	}
+	if g.dispatch == DispatchLeastLoaded {
+		best := g.members[0]
+		for _, m := range g.members[1:] {
+			if m.weight() < best.weight() {
+				best = m
+			}
+		}
+		return best
+	}
+	i := g.next.Add(1) - 1
+	return g.members[i%uint64(len(g.members))]
+}
+
+func (g *memGroup) remove(s *memSub) {
+	for i, m := range g.members {
+		if m == s {
+			g.members = append(g.members[:i:i], g.members[i+1:]...)
+			return
+		}
+	}
+}
+
+// weight งานที่ค้างของสมาชิก ใช้กับ DispatchLeastLoaded
+func (s *memSub) weight() int {
+	if s.tracked {
+		return int(s.pending.Load())
+	}
+	return s.load()
+}
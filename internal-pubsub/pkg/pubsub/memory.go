@@ -2,43 +2,74 @@ package pubsub
 
 import (
 	"context"
-	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// drainPollInterval ใช้ตรวจซ้ำระหว่าง drain เพราะ bus ไม่รู้ว่าผู้รับที่ไม่ได้ track ดึง event ออกจาก chan เมื่อไร
+// ส่วน Publish ที่จบ MarkDone และ Ack จะปลุก Close ทันทีผ่าน memoryBus.settled
+const drainPollInterval = 20 * time.Millisecond
+
 // Bus คือสัญญาใช้งาน Pub/Sub
 type Bus interface {
 	// Subscribe สมัครรับอีเวนต์ตาม topic
+	// topic อาจเป็น pattern ที่มี wildcard "*" (หนึ่งระดับ) หรือ "#" (ศูนย์ระดับขึ้นไป) ได้
 	// buffer ถ้า <= 0 จะใช้ค่าจาก Options.DefaultBuffer
-	Subscribe(topic Topic, buffer int) Subscription
+	// opts ใช้ override โหมดการส่งเฉพาะ subscription นี้ (ไม่ระบุ = ใช้ค่าของ bus)
+	Subscribe(topic Topic, buffer int, opts ...SubscribeOption) Subscription
 
 	// Publish ส่งอีเวนต์ (เคารพ ctx เมื่อ DeliveryMode เป็น Block/Timeout)
 	Publish(ctx context.Context, topic Topic, data any) error
 
-	// Close ปิด bus และปิด chan ของทุก subscriber (idempotent)
+	// Close หยุดรับ Publish ใหม่แล้วปิด chan ของทุก subscriber (idempotent)
+	// ถ้าเปิด Options.DrainOnClose จะรอให้ผู้รับประมวลผล event ที่ค้างอยู่จนหมดก่อน
+	// และถ้า ctx หมดเวลาก่อนจะคืน *DrainError ที่บอกจำนวน event ที่ถูกทิ้ง
 	Close(ctx context.Context) error
 }
 
 type memoryBus struct {
 	mu        sync.RWMutex
 	topics    map[Topic]map[*memSub]struct{}
-	closed    bool
+	patterns  map[Topic]map[*memSub]struct{}
+	groups    map[groupKey]*memGroup
+	closed    bool // หยุดรับ Publish จากภายนอก (เริ่ม drain)
+	sealed    bool // หยุดรับ Publish ทุกชนิด
 	opts      Options
 	closeOnce sync.Once
+
+	inflight  atomic.Int64  // จำนวน Publish ที่กำลังส่งอยู่
+	settled   chan struct{} // (buffer 1) ปลุก Close เมื่อ Publish จบหรือผู้รับทำ event เสร็จ
+	abort     chan struct{} // ปิดเมื่อ drain หมดเวลา เพื่อปลด Publish ที่บล็อกอยู่
+	abandoned atomic.Int64  // event ที่ส่งไม่สำเร็จเพราะถูก abort
+
+	topicStats sync.Map // Topic -> *counters
+	retained   *retainStore
+	sched      *scheduler
+	dedup      *dedupStore // nil = ไม่ตัด event ซ้ำ
 }
 
 func New(opts Options) Bus {
 	if opts.DefaultBuffer <= 0 {
 		opts.DefaultBuffer = DefaultOptions().DefaultBuffer
 	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
 	return &memoryBus{
-		topics: make(map[Topic]map[*memSub]struct{}),
-		opts:   opts,
+		topics:   make(map[Topic]map[*memSub]struct{}),
+		patterns: make(map[Topic]map[*memSub]struct{}),
+		groups:   make(map[groupKey]*memGroup),
+		opts:     opts,
+		abort:    make(chan struct{}),
+		settled:  make(chan struct{}, 1),
+		retained: newRetainStore(opts.Retain),
+		sched:    newScheduler(opts.Clock),
+		dedup:    newDedupStore(opts.Dedup, opts.Clock),
 	}
 }
 
-func (b *memoryBus) Subscribe(topic Topic, buffer int) Subscription {
+func (b *memoryBus) Subscribe(topic Topic, buffer int, opts ...SubscribeOption) Subscription {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -46,99 +77,283 @@ func (b *memoryBus) Subscribe(topic Topic, buffer int) Subscription {
 		// สร้าง sub ว่างที่ปิดแล้วจะทำให้ range ออกทันที
 		ch := make(chan Event)
 		close(ch)
-		return &memSub{bus: b, topic: topic, ch: ch, closed: true}
+		done := make(chan struct{})
+		close(done)
+		return &memSub{bus: b, topic: topic, ch: ch, done: done, closed: true, stopped: true}
 	}
 
 	if buffer <= 0 {
 		buffer = b.opts.DefaultBuffer
 	}
 
-	sub := &memSub{
-		bus: b, topic: topic,
-		ch: make(chan Event, buffer),
+	so := SubscribeOptions{
+		DeliveryMode:      b.opts.DeliveryMode,
+		DeliveryTimeoutMs: b.opts.DeliveryTimeoutMs,
+		DispatchQueue:     b.opts.DispatchQueue,
 	}
-	if b.topics[topic] == nil {
-		b.topics[topic] = make(map[*memSub]struct{})
+	for _, f := range opts {
+		f(&so)
 	}
-	b.topics[topic][sub] = struct{}{}
-	return sub
-}
 
-func (b *memoryBus) Publish(ctx context.Context, topic Topic, data any) error {
-	b.mu.RLock()
-	if b.closed {
-		b.mu.RUnlock()
-		return ErrClosed
+	// event ที่เก็บไว้ต้องใส่ chan ได้ทั้งหมดโดยไม่บล็อก (ถือ lock อยู่)
+	var retained []Event
+	if so.Group == "" {
+		retained = b.retained.match(topic)
 	}
-	// snapshot subscribers เพื่อหลีกเลี่ยง hold lock นานเกินไปตอนส่ง
-	subs := make([]*memSub, 0, len(b.topics[topic]))
-	for s := range b.topics[topic] {
-		subs = append(subs, s)
+	buffer = max(buffer, len(retained))
+
+	// โหมด ack: chan ไม่มี buffer เพื่อให้รู้ว่าผู้รับได้ event ไปตอนไหน (เริ่มนับ visibility)
+	// buffer กลายเป็นความจุคิวของ dispatcher แทน
+	ack := newAckTracker(so, b.opts.Clock)
+	if ack != nil {
+		so.DispatchQueue = max(so.DispatchQueue, buffer, 1)
+		buffer = 0
 	}
-	mode := b.opts.DeliveryMode
-	timeout := time.Duration(b.opts.DeliveryTimeoutMs) * time.Millisecond
-	b.mu.RUnlock()
 
-	ev := Event{Topic: topic, Data: data}
+	sub := &memSub{
+		id: newID(), bus: b, topic: topic,
+		pattern: topic.IsPattern(),
+		mode:    so.DeliveryMode,
+		tracked: so.TrackDone,
+		group:   so.Group,
+		timeout: time.Duration(so.DeliveryTimeoutMs) * time.Millisecond,
+		ch:      make(chan Event, buffer),
+		created: time.Now(),
 
-	for _, s := range subs {
-		switch mode {
-		case DeliveryDrop:
-			select {
-			case s.ch <- ev:
-			default:
-				// ทิ้ง ไม่บล็อก
-			}
-		case DeliveryTimeout:
-			if timeout <= 0 {
-				timeout = 100 * time.Millisecond
-			}
-			timer := time.NewTimer(timeout)
-			select {
-			case s.ch <- ev:
-				if !timer.Stop() {
-					<-timer.C
-				}
-			case <-timer.C:
-				// ทิ้งเพราะหมดเวลา
-			case <-ctx.Done():
-				if !timer.Stop() {
-					<-timer.C
-				}
-				return ctx.Err()
-			}
-		default: // DeliveryBlock (เคารพ ctx)
-			select {
-			case s.ch <- ev:
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+		queueCap: max(so.DispatchQueue, 0),
+		ack:      ack,
+		space:    make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	index := b.index(sub)
+	if index[topic] == nil {
+		index[topic] = make(map[*memSub]struct{})
+	}
+	index[topic][sub] = struct{}{}
+	if sub.group != "" {
+		key := groupKey{topic: topic, name: sub.group}
+		g := b.groups[key]
+		if g == nil {
+			g = &memGroup{dispatch: so.GroupDispatch}
+			b.groups[key] = g
 		}
+		g.members = append(g.members, sub)
 	}
-	return nil
+	for _, ev := range retained {
+		if sub.tracked {
+			sub.pending.Add(1)
+		}
+		if ack != nil {
+			sub.queue = append(sub.queue, ev)
+		} else {
+			sub.ch <- ev
+			sub.received()
+		}
+		b.record(sub, ev, outcomeDelivered)
+	}
+	if so.DeliveryMode == DeliverySpill {
+		sub.spill = newSpillQueue(b.opts.Spill)
+	}
+	if sub.queueCap > 0 || sub.spill != nil {
+		sub.dispatcherDone = make(chan struct{})
+		go sub.dispatch()
+	}
+	return sub
+}
+
+// Publish ระหว่าง drain จะรับเฉพาะที่มาจากภายใน handler (ctx มี event จาก ContextWithEvent)
+// เพื่อให้งานที่ค้างอยู่ส่งต่อกันจนจบสายได้ ดู PublishWithReport สำหรับรายละเอียดการส่งรายผู้รับ
+func (b *memoryBus) Publish(ctx context.Context, topic Topic, data any) error {
+	_, err := b.PublishWithReport(ctx, topic, data)
+	return err
+}
+
+// EventPublisher implement โดย Bus ที่ publish event ที่สร้างไว้แล้วได้ (คง ID, Time และ Headers เดิม)
+// ใช้โดย transport ที่รับ event มาจาก process อื่น
+type EventPublisher interface {
+	PublishEvent(ctx context.Context, ev Event) error
+}
+
+// PublishEvent เหมือน Publish แต่ใช้ event ที่สร้างไว้แล้ว
+func (b *memoryBus) PublishEvent(ctx context.Context, ev Event) error {
+	_, err := b.publish(ctx, ev)
+	return err
 }
 
 func (b *memoryBus) Close(ctx context.Context) error {
 	var err error
 	b.closeOnce.Do(func() {
+		b.closeScheduled(ctx)
+
 		b.mu.Lock()
-		defer b.mu.Unlock()
-		if b.closed {
-			return
-		}
 		b.closed = true
-		// ปิดทุก subscription channel
-		for _, set := range b.topics {
-			for s := range set {
-				s.closeNoLock()
+		b.mu.Unlock()
+
+		drain := b.opts.DrainOnClose
+		var werr error
+		if drain {
+			werr = b.waitIdle(ctx)
+		}
+
+		b.mu.Lock()
+		b.sealed = true
+		b.mu.Unlock()
+		if drain && werr == nil {
+			// เก็บตก Publish ที่เริ่มไปก่อน seal
+			werr = b.waitIdle(ctx)
+		}
+		if !drain || werr != nil {
+			close(b.abort)
+			b.waitPublishes(ctx)
+		}
+
+		b.mu.Lock()
+		abandoned := int(b.abandoned.Load())
+		var detached []*memSub
+		for _, index := range []map[Topic]map[*memSub]struct{}{b.topics, b.patterns} {
+			for _, set := range index {
+				for s := range set {
+					abandoned += s.backlog()
+					s.detachLocked()
+					detached = append(detached, s)
+				}
 			}
 		}
 		// ล้าง map เพื่อช่วย GC
 		b.topics = make(map[Topic]map[*memSub]struct{})
+		b.patterns = make(map[Topic]map[*memSub]struct{})
+		b.groups = make(map[groupKey]*memGroup)
+		b.mu.Unlock()
+
+		// ปิดทุก subscription channel หลังปล่อย lock (dispatcher อาจเรียก OnDrop ที่ Publish)
+		for _, s := range detached {
+			s.finish()
+		}
+		if werr != nil {
+			err = &DrainError{Abandoned: abandoned, Err: werr}
+		}
 	})
-	// เคารพ ctx เฉย ๆ แม้การปิดจะ instant
-	if ctx.Err() != nil && !errors.Is(ctx.Err(), context.Canceled) {
-		err = ctx.Err()
-	}
 	return err
 }
+
+// waitIdle รอจนไม่มี Publish ค้างและทุก subscription ว่าง หรือจน ctx หมดเวลา
+func (b *memoryBus) waitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for !b.idle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.settled:
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// waitPublishes รอ Publish ที่ถูกปลดด้วย abort ออกไปให้หมด เพื่อให้นับ abandoned ได้ครบ
+// แต่ไม่เกิน ctx เพื่อไม่ให้ Close ค้างถ้า OnDrop ของผู้ใช้ไม่คืน
+func (b *memoryBus) waitPublishes(ctx context.Context) {
+	for b.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.settled:
+		}
+	}
+}
+
+// settle ปลุก Close ที่รออยู่ให้ตรวจใหม่ (ไม่บล็อก สัญญาณที่ยังไม่ถูกอ่านจะรวมเป็นครั้งเดียว)
+func (b *memoryBus) settle() {
+	select {
+	case b.settled <- struct{}{}:
+	default:
+	}
+}
+
+func (b *memoryBus) idle() bool {
+	if b.inflight.Load() > 0 {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, index := range []map[Topic]map[*memSub]struct{}{b.topics, b.patterns} {
+		for _, set := range index {
+			for s := range set {
+				if s.backlog() > 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// HasSubscribers บอกว่ามี subscription ที่จะได้รับ topic นี้หรือไม่ (รวม pattern)
+func (b *memoryBus) HasSubscribers(topic Topic) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.hasMatchLocked(topic)
+}
+
+// index คืน map ที่ sub นี้ต้องถูกเก็บ (exact หรือ pattern)
+func (b *memoryBus) index(s *memSub) map[Topic]map[*memSub]struct{} {
+	if s.pattern {
+		return b.patterns
+	}
+	return b.topics
+}
+
+// hasMatchLocked บอกว่ามี subscriber ที่ตรงกับ topic หรือไม่ (ต้องถือ lock อยู่)
+// ไม่เลือกสมาชิกของ group จึงไม่ขยับลำดับ round-robin
+func (b *memoryBus) hasMatchLocked(topic Topic) bool {
+	for s := range b.topics[topic] {
+		if s.group == "" {
+			return true
+		}
+	}
+	for pattern, set := range b.patterns {
+		if !Match(pattern, topic) {
+			continue
+		}
+		for s := range set {
+			if s.group == "" {
+				return true
+			}
+		}
+	}
+	for key, g := range b.groups {
+		if len(g.members) > 0 && Match(key.topic, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLocked รวบรวม subscriber ที่ต้องได้รับ topic นี้ ทั้ง exact และ pattern (ต้องถือ lock อยู่)
+// สมาชิกของ consumer group ไม่อยู่ใน subs แต่คืนเป็น group ที่ตรงกันให้ผู้ publish เลือกสมาชิกเอง
+func (b *memoryBus) matchLocked(topic Topic) (subs []*memSub, groups []*memGroup) {
+	subs = make([]*memSub, 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		if s.group == "" {
+			subs = append(subs, s)
+		}
+	}
+	for pattern, set := range b.patterns {
+		if !Match(pattern, topic) {
+			continue
+		}
+		for s := range set {
+			if s.group == "" {
+				subs = append(subs, s)
+			}
+		}
+	}
+	for key, g := range b.groups {
+		if Match(key.topic, topic) {
+			groups = append(groups, g)
+		}
+	}
+	return subs, groups
+}
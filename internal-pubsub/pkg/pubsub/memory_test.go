@@ -0,0 +1,350 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishRoutesToExactAndPatternSubscribers(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	exact := bus.Subscribe("order.created", 4)
+	star := bus.Subscribe("order.*", 4)
+	hash := bus.Subscribe("#", 4)
+	other := bus.Subscribe("invoice.*", 4)
+
+	if err := bus.Publish(context.Background(), "order.created", "ORD-1"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for name, sub := range map[string]Subscription{"exact": exact, "star": star, "hash": hash} {
+		select {
+		case ev := <-sub.C():
+			if ev.Topic != "order.created" || ev.Data != "ORD-1" {
+				t.Errorf("%s: unexpected event %+v", name, ev)
+			}
+		default:
+			t.Errorf("%s: expected event", name)
+		}
+	}
+	select {
+	case ev := <-other.C():
+		t.Errorf("invoice.*: unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestUnsubscribePatternCleansUp(t *testing.T) {
+	bus := New(DefaultOptions()).(*memoryBus)
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.#", 1)
+	sub.Unsubscribe()
+
+	if _, ok := <-sub.C(); ok {
+		t.Fatal("expected channel to be closed")
+	}
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	if len(bus.patterns) != 0 {
+		t.Fatalf("expected pattern index to be empty, got %d", len(bus.patterns))
+	}
+}
+
+func TestSubscribeDeliveryModeOverride(t *testing.T) {
+	bus := New(DefaultOptions()) // DeliveryBlock
+	defer closeNow(bus)
+
+	metrics := bus.Subscribe("order.created", 1, WithDeliveryMode(DeliveryDrop))
+	invoices := bus.Subscribe("order.created", 2)
+
+	// metrics เต็มหลังตัวแรก แต่ต้องไม่บล็อก publisher
+	for i := range 2 {
+		if err := bus.Publish(context.Background(), "order.created", i); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	if got := len(metrics.C()); got != 1 {
+		t.Errorf("metrics buffered %d events, want 1", got)
+	}
+	if got := len(invoices.C()); got != 2 {
+		t.Errorf("invoices buffered %d events, want 2", got)
+	}
+}
+
+func TestDropAccountingAndOnDrop(t *testing.T) {
+	var drops []DropReason
+	opts := DefaultOptions()
+	opts.DeliveryMode = DeliveryDrop
+	opts.OnDrop = func(topic Topic, ev Event, reason DropReason) { drops = append(drops, reason) }
+	bus := New(opts)
+	defer closeNow(bus)
+
+	bus.Subscribe("order.created", 1)
+	bus.Subscribe("order.*", 1, WithDeliveryMode(DeliveryTimeout), WithDeliveryTimeout(time.Millisecond))
+
+	for i := range 3 {
+		_ = bus.Publish(context.Background(), "order.created", i)
+	}
+
+	st := bus.(StatsReporter).Stats()
+	want := Counters{Delivered: 2, Dropped: 2, TimedOut: 2}
+	if got := st.Topics["order.created"]; got != want {
+		t.Errorf("topic counters = %+v, want %+v", got, want)
+	}
+	for _, ss := range st.Subscriptions {
+		want := Counters{Delivered: 1, Dropped: 2}
+		if ss.Topic == "order.*" {
+			want = Counters{Delivered: 1, TimedOut: 2}
+		}
+		if ss.Counters != want {
+			t.Errorf("%s counters = %+v, want %+v", ss.Topic, ss.Counters, want)
+		}
+	}
+	if len(drops) != 4 {
+		t.Errorf("OnDrop called %d times, want 4", len(drops))
+	}
+}
+
+// closeNow ปิด bus โดยไม่รอ event ที่ test ไม่ได้อ่าน
+func closeNow(bus Bus) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = bus.Close(ctx)
+}
+
+func TestCloseWithoutDrainDoesNotWait(t *testing.T) {
+	bus := New(DefaultOptions())
+	sub := bus.Subscribe("order.created", 2, WithDoneTracking())
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	_ = bus.Publish(context.Background(), "order.created", "ORD-2")
+	<-sub.C() // รับไปแล้วแต่ไม่ MarkDone
+
+	done := make(chan error, 1)
+	go func() { done <- bus.Close(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("close = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on unread backlog without DrainOnClose")
+	}
+	for range sub.C() {
+	}
+}
+
+func TestCloseDoesNotHangOnStuckPublish(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	opts := DefaultOptions()
+	opts.DeliveryMode = DeliveryDrop
+	opts.OnDrop = func(Topic, Event, DropReason) { // OnDrop ที่ไม่คืน
+		close(entered)
+		<-release
+	}
+	bus := New(opts)
+
+	bus.Subscribe("order.created", 1)
+	_ = bus.Publish(context.Background(), "order.created", 1)
+	go bus.Publish(context.Background(), "order.created", 2)
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- bus.Close(ctx) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close ignored ctx while a Publish was stuck")
+	}
+}
+
+func TestConsumerGroupDeliversToOneMember(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	var workers []Subscription
+	for range 3 {
+		workers = append(workers, bus.Subscribe("order.created", 8, WithGroup("invoicing")))
+	}
+	audit := bus.Subscribe("order.created", 8)
+
+	for i := range 6 {
+		if err := bus.Publish(context.Background(), "order.created", i); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	for i, w := range workers {
+		if got := len(w.C()); got != 2 {
+			t.Errorf("worker %d got %d events, want 2 (round-robin)", i, got)
+		}
+	}
+	if got := len(audit.C()); got != 6 {
+		t.Errorf("broadcast subscriber got %d events, want 6", got)
+	}
+
+	// สมาชิกที่ออกไปต้องไม่ได้รับอีก และ group ต้องหายเมื่อไม่มีสมาชิก
+	for _, w := range workers[1:] {
+		w.Unsubscribe()
+	}
+	_ = bus.Publish(context.Background(), "order.created", 6)
+	if got := len(workers[0].C()); got != 3 {
+		t.Errorf("remaining worker got %d events, want 3", got)
+	}
+	workers[0].Unsubscribe()
+	mb := bus.(*memoryBus)
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	if len(mb.groups) != 0 {
+		t.Errorf("expected group to be removed, got %d", len(mb.groups))
+	}
+}
+
+func TestConsumerGroupLeastLoaded(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	busy := bus.Subscribe("order.created", 8, WithGroup("invoicing"), WithGroupDispatch(DispatchLeastLoaded))
+	idle := bus.Subscribe("order.created", 8, WithGroup("invoicing"))
+
+	_ = bus.Publish(context.Background(), "order.created", 0) // ไป busy (เสมอกัน เลือกตัวแรก)
+	for i := 1; i < 4; i++ {
+		_ = bus.Publish(context.Background(), "order.created", i)
+		<-idle.C() // idle ประมวลผลทันที จึงว่างกว่าเสมอ
+	}
+	if got := len(busy.C()); got != 1 {
+		t.Errorf("busy member got %d events, want 1", got)
+	}
+}
+
+func TestRetainedEventsForLateSubscribers(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Retain = map[Topic]int{"config.updated": 1, "price.#": 2}
+	bus := New(opts)
+	defer closeNow(bus)
+
+	ctx := context.Background()
+	_ = bus.Publish(ctx, "config.updated", "v1")
+	_ = bus.Publish(ctx, "config.updated", "v2")
+	for i := range 3 {
+		_ = bus.Publish(ctx, "price.changed", i)
+	}
+	_ = bus.Publish(ctx, "order.created", "not retained")
+
+	cfg := bus.Subscribe("config.updated", 1)
+	if ev := <-cfg.C(); ev.Data != "v2" {
+		t.Fatalf("config got %v, want v2", ev.Data)
+	}
+	prices := bus.Subscribe("price.*", 1)
+	for _, want := range []int{1, 2} {
+		if ev := <-prices.C(); ev.Data != want {
+			t.Fatalf("price got %v, want %d", ev.Data, want)
+		}
+	}
+
+	// retained มาก่อน แล้วตามด้วย event สด
+	_ = bus.Publish(ctx, "config.updated", "v3")
+	if ev := <-cfg.C(); ev.Data != "v3" {
+		t.Fatalf("live config got %v, want v3", ev.Data)
+	}
+
+	bus.(Retainer).ClearRetained("config.#")
+	if got := bus.(Retainer).Retained("config.updated"); len(got) != 0 {
+		t.Fatalf("expected cleared retained state, got %d", len(got))
+	}
+	late := bus.Subscribe("config.updated", 1)
+	select {
+	case ev := <-late.C():
+		t.Fatalf("unexpected retained event %+v after clear", ev)
+	default:
+	}
+}
+
+func TestRetainPicksMostSpecificPattern(t *testing.T) {
+	policy := map[Topic]int{"config.#": 1, "config.*": 2, "config.db.*": 3, "#": 4, "config.db.url": 5}
+	cases := map[Topic]int{
+		"config.db.url":  5, // exact ชนะ pattern
+		"config.db.host": 3,
+		"config.app":     2,
+		"config.a.b":     1,
+		"order.created":  4,
+	}
+	// วนหลายรอบเพราะลำดับของ map สุ่มทุกครั้ง
+	for range 20 {
+		r := newRetainStore(policy)
+		for topic, want := range cases {
+			if got := r.limit(topic); got != want {
+				t.Fatalf("limit(%s) = %d, want %d", topic, got, want)
+			}
+		}
+	}
+}
+
+func TestInspectorReportsStuckSubscriber(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	bus.Subscribe("order.created", 1)
+	bus.Subscribe("order.*", 4, WithGroup("audit"))
+
+	_ = bus.Publish(context.Background(), "order.created", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go bus.Publish(ctx, "order.created", 2) // บล็อกเพราะ chan แรกเต็ม
+
+	insp := bus.(Inspector)
+	var stuck SubscriptionStats
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, ss := range insp.Stats().Subscriptions {
+			if ss.Topic == "order.created" {
+				stuck = ss
+			}
+		}
+		if stuck.BlockedPublishers == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stuck.Topic != "order.created" || stuck.BlockedPublishers != 1 ||
+		stuck.Capacity != 1 || stuck.Length != 1 || stuck.LastReceive.IsZero() {
+		t.Fatalf("unexpected stats %+v", stuck)
+	}
+
+	topics := insp.Topics()
+	if len(topics) != 2 || topics[0].Topic != "order.*" || !topics[0].Pattern ||
+		len(topics[0].Groups) != 1 || topics[0].Groups[0] != "audit" {
+		t.Fatalf("unexpected topics %+v", topics)
+	}
+}
+
+func TestLastReceiveIgnoresQueuedEvents(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1, WithDispatchQueue(4))
+	stats := func() SubscriptionStats { return bus.(Inspector).Stats().Subscriptions[0] }
+
+	_ = bus.Publish(context.Background(), "order.created", 1)
+	first := stats().LastReceive
+	time.Sleep(2 * time.Millisecond)
+	_ = bus.Publish(context.Background(), "order.created", 2) // chan เต็ม จึงค้างในคิว
+	if st := stats(); st.Queued != 1 || !st.LastReceive.Equal(first) {
+		t.Fatalf("queued event moved LastReceive: %+v (first %v)", st, first)
+	}
+
+	<-sub.C()
+	deadline := time.Now().Add(time.Second)
+	for stats().Queued != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if st := stats(); !st.LastReceive.After(first) {
+		t.Fatalf("expected LastReceive to advance once dispatched: %+v", st)
+	}
+}
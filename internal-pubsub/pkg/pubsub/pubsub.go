@@ -1,12 +1,22 @@
 package pubsub
 
+import "time"
+
 // Topic คือชื่อหัวข้อสำหรับกระจายอีเวนต์
 type Topic string
 
 // Event คือข้อมูลอีเวนต์ที่ส่งข้ามโมดูล
+// Headers ถูกแชร์ระหว่างผู้รับทุกคนของ event เดียวกัน ห้ามแก้ไข
 type Event struct {
-	Topic Topic
-	Data  any
+	ID      string // ไอดีเฉพาะของ event
+	Topic   Topic
+	Data    any
+	Time    time.Time         // เวลาที่ publish
+	Headers map[string]string // metadata เช่น correlation-id, causation-id
+
+	// Attempt ครั้งที่ event นี้ถูกส่งให้ผู้รับ (1 = ครั้งแรก) เฉพาะ subscription ที่สมัครด้วย WithAck
+	// ค่าเป็น 0 สำหรับ subscription ทั่วไป
+	Attempt int
 }
 
 // DeliveryMode กำหนดกลยุทธ์การส่ง
@@ -19,13 +29,42 @@ const (
 	DeliveryDrop
 	// DeliveryTimeout: พยายามส่งจนถึง timeout ที่กำหนดไว้ใน Options แล้วค่อยทิ้ง
 	DeliveryTimeout
+	// DeliverySpill: ถ้า chan (และคิวของ dispatcher) เต็ม ให้เขียนลงคิวบนดิสก์แทน แล้วป้อนกลับตามลำดับ
+	// เมื่อผู้รับตามทัน ไม่บล็อก publisher และทิ้งเฉพาะเมื่อเกินเพดานใน Options.Spill
+	DeliverySpill
 )
 
 // Options ปรับแต่งพฤติกรรมของ Bus
 type Options struct {
 	DefaultBuffer     int          // ความจุ chan เริ่มต้นของ subscriber (ถ้าไม่ระบุ)
-	DeliveryMode      DeliveryMode // โหมดการส่ง
+	DeliveryMode      DeliveryMode // โหมดการส่งเริ่มต้น (override ต่อ subscription ได้ด้วย WithDeliveryMode)
 	DeliveryTimeoutMs int          // ใช้เมื่อ DeliveryTimeout (>0)
+	DispatchQueue     int          // ความจุคิวของ dispatcher ต่อ subscription (0 = ไม่มีคิว)
+
+	// OnDrop ถูกเรียกทุกครั้งที่ event ไม่ถึงผู้รับ (DeliveryDrop/DeliveryTimeout)
+	// ทำงานใน goroutine ของ Publish (หรือ dispatcher ของ subscription) จึงควรทำงานเร็วและห้าม Publish ซ้ำแบบบล็อก
+	OnDrop func(topic Topic, ev Event, reason DropReason)
+
+	// Retain เปิดการเก็บ event ล่าสุด N ตัวต่อ topic (key เป็น pattern ได้ เช่น "config.#": 1)
+	// ถ้าหลาย pattern ตรงกับ topic เดียวกัน ใช้ตัวที่เจาะจงที่สุด (มีระดับที่เป็นคำจริงมากกว่า)
+	// Subscribe ใหม่จะได้ event ที่เก็บไว้ก่อน แล้วจึงตามด้วย event สด
+	// (ไม่ส่งให้สมาชิก consumer group เพื่อไม่ให้ซ้ำภายใน group)
+	Retain map[Topic]int
+
+	// Clock แหล่งเวลาของ PublishAfter/PublishAt (nil = SystemClock)
+	Clock Clock
+	// ScheduleOnClose สิ่งที่ Close ทำกับ event ที่ตั้งเวลาไว้แต่ยังไม่ถึงเวลา (ค่าเริ่มต้นทิ้ง)
+	ScheduleOnClose SchedulePolicy
+
+	// Spill ตั้งค่าคิวบนดิสก์ของ subscription ที่ใช้ DeliverySpill
+	Spill SpillOptions
+
+	// Dedup ตัด event ที่ publish ซ้ำด้วย idempotency key เดียวกัน (ดู WithIdempotencyKey)
+	Dedup DedupOptions
+
+	// DrainOnClose ให้ Close รอผู้รับประมวลผล event ที่ค้างอยู่จนหมด (หรือจน ctx หมดเวลา) ก่อนปิด
+	// ค่าเริ่มต้น false: Close ปิดทันทีและทิ้ง event ที่ค้าง ควรใช้คู่กับ ctx ที่มี timeout
+	DrainOnClose bool
 }
 
 // DefaultOptions ค่าปริยาย
@@ -36,3 +75,82 @@ func DefaultOptions() Options {
 		DeliveryTimeoutMs: 0,
 	}
 }
+
+// SubscribeOptions ปรับพฤติกรรมการส่งเฉพาะ subscription หนึ่งตัว
+// ค่าเริ่มต้นมาจาก Options ของ bus
+type SubscribeOptions struct {
+	DeliveryMode      DeliveryMode
+	DeliveryTimeoutMs int
+
+	// DispatchQueue > 0: subscription นี้มี dispatcher goroutine และคิวขนาดนี้ของตัวเอง
+	// เมื่อ chan เต็ม event จะเข้าคิวแทนโดยไม่บล็อก publisher ผู้รับที่ช้าจึงกระทบแค่ตัวเอง
+	// publisher จะรอ (ตาม DeliveryMode) ก็ต่อเมื่อคิวเต็มแล้วเท่านั้น
+	DispatchQueue int
+
+	// TrackDone: ผู้รับสัญญาว่าจะเรียก DoneMarker.MarkDone หลังประมวลผลแต่ละ event
+	// ทำให้ Close (เมื่อเปิด Options.DrainOnClose) รอจน handler ที่กำลังทำงานเสร็จ ไม่ใช่แค่จน chan ว่าง
+	TrackDone bool
+
+	// Group ถ้าไม่ว่าง: subscription ที่ใช้ topic และชื่อ group เดียวกันจะแบ่งกันรับ event
+	// (แต่ละ event ไปถึงสมาชิกเพียงคนเดียว) ส่วน subscription ปกติยังได้ทุก event เหมือนเดิม
+	Group         string
+	GroupDispatch GroupDispatch // ใช้ค่าของสมาชิกคนแรกที่เข้า group
+
+	// AckVisibilityMs > 0: ผู้รับต้อง Ack ทุก event (ดู Acker) ภายในเวลานี้นับจากที่รับจาก C()
+	// ไม่อย่างนั้น event จะถูกส่งใหม่โดยเพิ่ม Event.Attempt
+	AckVisibilityMs int
+	// MaxDeliveries จำนวนครั้งสูงสุดที่ส่ง event หนึ่งตัวในโหมด ack (<= 0 = ไม่จำกัด)
+	// เกินแล้วทิ้งด้วย DropMaxDeliveries
+	MaxDeliveries int
+
+	// Consumer ชื่อที่ backend แบบเก็บถาวร (เช่น filebus) ใช้จำตำแหน่งอ่านข้าม restart
+	// memory bus ไม่ใช้ค่านี้
+	Consumer string
+}
+
+type SubscribeOption func(*SubscribeOptions)
+
+// WithDeliveryMode กำหนดโหมดการส่งของ subscription นี้
+func WithDeliveryMode(mode DeliveryMode) SubscribeOption {
+	return func(o *SubscribeOptions) { o.DeliveryMode = mode }
+}
+
+// WithDeliveryTimeout กำหนด timeout ของ subscription นี้ (ใช้กับ DeliveryTimeout)
+func WithDeliveryTimeout(d time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) { o.DeliveryTimeoutMs = int(d / time.Millisecond) }
+}
+
+// WithDoneTracking ให้ Close ที่ drain รอจนผู้รับ MarkDone ครบทุก event (ดู DoneMarker, Options.DrainOnClose)
+func WithDoneTracking() SubscribeOption {
+	return func(o *SubscribeOptions) { o.TrackDone = true }
+}
+
+// WithGroup เข้าร่วม consumer group ชื่อ name (competing consumers)
+func WithGroup(name string) SubscribeOption {
+	return func(o *SubscribeOptions) { o.Group = name }
+}
+
+// WithGroupDispatch กำหนดวิธีเลือกสมาชิกภายใน group
+func WithGroupDispatch(d GroupDispatch) SubscribeOption {
+	return func(o *SubscribeOptions) { o.GroupDispatch = d }
+}
+
+// WithAck เปิดโหมด ack: event ที่ไม่ถูก Ack ภายใน visibility จะถูกส่งใหม่ (ดู Acker)
+func WithAck(visibility time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) { o.AckVisibilityMs = int(visibility / time.Millisecond) }
+}
+
+// WithMaxDeliveries จำกัดจำนวนครั้งที่ส่ง event หนึ่งตัวในโหมด ack
+func WithMaxDeliveries(n int) SubscribeOption {
+	return func(o *SubscribeOptions) { o.MaxDeliveries = n }
+}
+
+// WithConsumer ให้ backend แบบเก็บถาวรจำตำแหน่งอ่านของ subscription นี้ไว้ในชื่อ name (ดู SubscribeOptions.Consumer)
+func WithConsumer(name string) SubscribeOption {
+	return func(o *SubscribeOptions) { o.Consumer = name }
+}
+
+// WithDispatchQueue ให้ subscription นี้มีคิวของ dispatcher ขนาด n (ดู SubscribeOptions.DispatchQueue)
+func WithDispatchQueue(n int) SubscribeOption {
+	return func(o *SubscribeOptions) { o.DispatchQueue = n }
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// HeaderReplyTo topic (inbox) ที่ผู้ตอบต้องส่งคำตอบกลับไป
+	HeaderReplyTo = "reply-to"
+	// HeaderReplyError ข้อความ error เมื่อผู้ตอบประมวลผลไม่สำเร็จ
+	HeaderReplyError = "reply-error"
+
+	inboxPrefix = "_inbox."
+)
+
+// RequestHandler ประมวลผลคำขอและคืนข้อมูลที่จะตอบกลับ
+type RequestHandler func(ctx context.Context, req Event) (any, error)
+
+// SubscriberChecker implement โดย Bus ที่ตรวจได้ว่ามีผู้รับ topic หรือไม่
+type SubscriberChecker interface {
+	HasSubscribers(topic Topic) bool
+}
+
+// Request ส่งคำขอไปยัง topic แล้วรอคำตอบแรกจนกว่า ctx จะหมดเวลา
+// คืน ErrNoResponders ถ้ารู้ได้ว่าไม่มีผู้รับ และ *ResponderError ถ้าผู้ตอบคืน error
+func Request(ctx context.Context, bus Bus, topic Topic, data any) (Event, error) {
+	if c, ok := bus.(SubscriberChecker); ok && !c.HasSubscribers(topic) {
+		return Event{}, fmt.Errorf("%w topic=%s", ErrNoResponders, topic)
+	}
+
+	// inbox เฉพาะคำขอนี้ รับคำตอบแรกเท่านั้น ที่เหลือทิ้งเพื่อไม่ให้ผู้ตอบบล็อก
+	inbox := Topic(inboxPrefix + newID())
+	sub := bus.Subscribe(inbox, 1, WithDeliveryMode(DeliveryDrop))
+	defer sub.Unsubscribe()
+
+	reqCtx := WithHeaders(ctx, map[string]string{HeaderReplyTo: string(inbox)})
+	if err := bus.Publish(reqCtx, topic, data); err != nil {
+		return Event{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	case reply, ok := <-sub.C():
+		if !ok {
+			return Event{}, ErrClosed
+		}
+		if msg, failed := reply.Headers[HeaderReplyError]; failed {
+			return reply, &ResponderError{Topic: topic, Message: msg}
+		}
+		return reply, nil
+	}
+}
+
+// Reply ส่งคำตอบของ req กลับไปยัง inbox ของผู้ขอ
+func Reply(ctx context.Context, bus Bus, req Event, data any) error {
+	return reply(ctx, bus, req, data, nil)
+}
+
+// Responder ห่อ RequestHandler ให้เป็น handler สำหรับ subscriber.Run
+// error จาก h จะถูกส่งกลับไปให้ผู้ขอ (ไม่คืนให้ Run เพื่อไม่ให้ retry แล้วตอบซ้ำ)
+func Responder(bus Bus, h RequestHandler) func(ctx context.Context, ev Event) error {
+	return func(ctx context.Context, ev Event) error {
+		if ev.Headers[HeaderReplyTo] == "" {
+			return fmt.Errorf("pubsub: event %s on topic %s is not a request", ev.ID, ev.Topic)
+		}
+		data, err := h(ctx, ev)
+		return reply(ctx, bus, ev, data, err)
+	}
+}
+
+func reply(ctx context.Context, bus Bus, req Event, data any, herr error) error {
+	inbox := Topic(req.Headers[HeaderReplyTo])
+	if inbox == "" {
+		return fmt.Errorf("pubsub: event %s has no %s header", req.ID, HeaderReplyTo)
+	}
+	ctx = ContextWithEvent(ctx, req)
+	if herr != nil {
+		ctx = WithHeaders(ctx, map[string]string{HeaderReplyError: herr.Error()})
+	}
+	return bus.Publish(ctx, inbox, data)
+}
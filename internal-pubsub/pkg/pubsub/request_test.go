@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestReply(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("price.compute", 1)
+	respond := Responder(bus, func(ctx context.Context, req Event) (any, error) {
+		if req.Data == "bad" {
+			return nil, errors.New("unknown order")
+		}
+		return 1990, nil
+	})
+	go func() {
+		for ev := range sub.C() {
+			_ = respond(context.Background(), ev)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := Request(ctx, bus, "price.compute", "ORD-1")
+	if err != nil || reply.Data != 1990 {
+		t.Fatalf("reply = %+v err = %v", reply, err)
+	}
+	if reply.CausationID() == "" {
+		t.Errorf("expected reply to carry causation id")
+	}
+
+	_, err = Request(ctx, bus, "price.compute", "bad")
+	var rerr *ResponderError
+	if !errors.As(err, &rerr) || rerr.Message != "unknown order" {
+		t.Fatalf("expected ResponderError, got %v", err)
+	}
+
+	if _, err := Request(ctx, bus, "nobody.home", nil); !errors.Is(err, ErrNoResponders) {
+		t.Fatalf("expected ErrNoResponders, got %v", err)
+	}
+}
+
+func TestRequestRoundRobinGroup(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	for _, name := range []string{"a", "b"} {
+		sub := bus.Subscribe("price.compute", 1, WithGroup("pricing"))
+		respond := Responder(bus, func(ctx context.Context, req Event) (any, error) {
+			return name, nil
+		})
+		go func() {
+			for ev := range sub.C() {
+				_ = respond(context.Background(), ev)
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	got := map[any]int{}
+	for range 4 {
+		reply, err := Request(ctx, bus, "price.compute", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[reply.Data]++
+	}
+	if got["a"] != 2 || got["b"] != 2 {
+		t.Fatalf("replies per member = %v, want 2 each", got)
+	}
+}
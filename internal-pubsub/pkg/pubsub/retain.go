@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Retainer implement โดย Bus ที่เก็บ event ล่าสุดของ topic ไว้ให้ผู้สมัครที่มาทีหลัง
+type Retainer interface {
+	// Retained คืน event ที่เก็บไว้ของ topic (หรือ pattern) เรียงตามเวลา publish
+	Retained(topic Topic) []Event
+	// ClearRetained ล้าง event ที่เก็บไว้ของ topic (หรือ pattern)
+	ClearRetained(topic Topic)
+}
+
+// retainStore เก็บ event ล่าสุด n ตัวต่อ topic
+type retainStore struct {
+	mu       sync.Mutex
+	policy   map[Topic]int // topic หรือ pattern -> จำนวนที่เก็บ
+	patterns []Topic       // key ที่เป็น pattern เรียงจากเจาะจงที่สุด (ดู comparePatterns)
+	events   map[Topic][]Event
+}
+
+func newRetainStore(policy map[Topic]int) *retainStore {
+	r := &retainStore{
+		policy: maps.Clone(policy),
+		events: make(map[Topic][]Event),
+	}
+	for t := range policy {
+		if t.IsPattern() {
+			r.patterns = append(r.patterns, t)
+		}
+	}
+	slices.SortFunc(r.patterns, comparePatterns)
+	return r
+}
+
+// limit คืนจำนวน event ที่ต้องเก็บของ topic (exact ก่อน แล้วค่อย pattern ที่เจาะจงที่สุด)
+func (r *retainStore) limit(topic Topic) int {
+	if n, ok := r.policy[topic]; ok {
+		return n
+	}
+	for _, pattern := range r.patterns {
+		if Match(pattern, topic) {
+			return r.policy[pattern]
+		}
+	}
+	return 0
+}
+
+func (r *retainStore) add(ev Event) {
+	n := r.limit(ev.Topic)
+	if n <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := append(r.events[ev.Topic], ev)
+	if len(kept) > n {
+		kept = slices.Clone(kept[len(kept)-n:])
+	}
+	r.events[ev.Topic] = kept
+}
+
+// match คืน event ที่เก็บไว้ของทุก topic ที่ตรงกับ topic/pattern เรียงตามเวลา
+func (r *retainStore) match(topic Topic) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Event
+	for t, evs := range r.events {
+		if Match(topic, t) {
+			out = append(out, evs...)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Event) int { return a.Time.Compare(b.Time) })
+	return out
+}
+
+func (r *retainStore) clear(topic Topic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for t := range r.events {
+		if Match(topic, t) {
+			delete(r.events, t)
+		}
+	}
+}
+
+func (b *memoryBus) Retained(topic Topic) []Event { return b.retained.match(topic) }
+
+func (b *memoryBus) ClearRetained(topic Topic) { b.retained.clear(topic) }
@@ -0,0 +1,177 @@
+package pubsub
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SchedulePolicy กำหนดว่า Close จะทำอย่างไรกับ event ที่ตั้งเวลาไว้แต่ยังไม่ถึงเวลา
+type SchedulePolicy int
+
+const (
+	// ScheduleDiscard: ทิ้ง (Scheduled.Err คืน ErrClosed)
+	ScheduleDiscard SchedulePolicy = iota
+	// ScheduleFlush: publish ทันทีตามลำดับเวลาที่ตั้งไว้ ก่อนเริ่ม drain
+	ScheduleFlush
+)
+
+// Scheduler implement โดย Bus ที่ publish แบบหน่วงเวลาได้
+// event ถูกสร้างตอนตั้งเวลา (ID และ header จาก ctx ถูกกำหนดทันที) ส่วน Time คือเวลาที่ publish จริง
+// การยกเลิก ctx ไม่ยกเลิกการตั้งเวลา ให้ใช้ Scheduled.Cancel
+type Scheduler interface {
+	PublishAfter(ctx context.Context, d time.Duration, topic Topic, data any) (Scheduled, error)
+	PublishAt(ctx context.Context, at time.Time, topic Topic, data any) (Scheduled, error)
+}
+
+// Scheduled handle ของ event ที่ตั้งเวลาไว้
+type Scheduled interface {
+	ID() string // ID ของ event ที่จะถูก publish
+	At() time.Time
+	// Cancel ยกเลิกถ้ายังไม่ถึงเวลา คืน false ถ้า publish ไปแล้ว/กำลัง publish หรือถูกยกเลิกไปแล้ว
+	Cancel() bool
+	// Done ถูกปิดเมื่อ publish เสร็จ ถูกยกเลิก หรือถูกทิ้งตอน Close
+	Done() <-chan struct{}
+	// Err รอจน Done แล้วคืนผลลัพธ์: nil ถ้า publish สำเร็จ, ErrScheduleCanceled, ErrClosed หรือ error จาก Publish
+	Err() error
+}
+
+// scheduler เก็บ event ที่รอเวลาของ memoryBus
+type scheduler struct {
+	clock   Clock
+	mu      sync.Mutex
+	pending map[*scheduledEvent]struct{}
+	closed  bool
+	firing  sync.WaitGroup // timer ที่กำลัง publish อยู่
+}
+
+func newScheduler(clock Clock) *scheduler {
+	return &scheduler{clock: clock, pending: make(map[*scheduledEvent]struct{})}
+}
+
+type scheduledEvent struct {
+	sched *scheduler
+	ev    Event
+	at    time.Time
+	ctx   context.Context // ctx ตอนตั้งเวลา (ตัด cancel ออกแล้ว) ใช้ตอน publish
+	timer Timer
+	done  chan struct{}
+	err   error
+}
+
+func (se *scheduledEvent) ID() string            { return se.ev.ID }
+func (se *scheduledEvent) At() time.Time         { return se.at }
+func (se *scheduledEvent) Done() <-chan struct{} { return se.done }
+
+func (se *scheduledEvent) Err() error {
+	<-se.done
+	return se.err
+}
+
+func (se *scheduledEvent) Cancel() bool {
+	s := se.sched
+	s.mu.Lock()
+	if _, ok := s.pending[se]; !ok {
+		s.mu.Unlock()
+		return false
+	}
+	delete(s.pending, se)
+	s.mu.Unlock()
+	se.timer.Stop()
+	se.finish(ErrScheduleCanceled)
+	return true
+}
+
+func (se *scheduledEvent) finish(err error) {
+	se.err = err
+	close(se.done)
+}
+
+// take ดึง se ออกจาก pending เพื่อ publish คืน false ถ้าถูกยกเลิกหรือ scheduler ปิดแล้ว
+func (s *scheduler) take(se *scheduledEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[se]; !ok || s.closed {
+		return false
+	}
+	delete(s.pending, se)
+	s.firing.Add(1)
+	return true
+}
+
+// shutdown หยุดรับการตั้งเวลาใหม่ รอ timer ที่กำลัง publish แล้วคืน event ที่ค้างเรียงตามเวลา
+func (s *scheduler) shutdown() []*scheduledEvent {
+	s.mu.Lock()
+	s.closed = true
+	left := make([]*scheduledEvent, 0, len(s.pending))
+	for se := range s.pending {
+		left = append(left, se)
+	}
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, se := range left {
+		se.timer.Stop()
+	}
+	s.firing.Wait()
+	slices.SortFunc(left, func(a, b *scheduledEvent) int { return a.at.Compare(b.at) })
+	return left
+}
+
+// PublishAfter publish event เมื่อครบ d นับจากตอนนี้ (ตาม Options.Clock)
+func (b *memoryBus) PublishAfter(ctx context.Context, d time.Duration, topic Topic, data any) (Scheduled, error) {
+	return b.PublishAt(ctx, b.sched.clock.Now().Add(d), topic, data)
+}
+
+// PublishAt publish event ณ เวลา at (ถ้าเลยมาแล้วจะ publish ทันทีใน goroutine แยก)
+func (b *memoryBus) PublishAt(ctx context.Context, at time.Time, topic Topic, data any) (Scheduled, error) {
+	b.mu.RLock()
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	s := b.sched
+	se := &scheduledEvent{
+		sched: s,
+		ev:    NewEventAt(ctx, topic, data, b.opts.Clock.Now()),
+		at:    at,
+		ctx:   context.WithoutCancel(ctx),
+		done:  make(chan struct{}),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	s.pending[se] = struct{}{}
+	se.timer = s.clock.AfterFunc(max(at.Sub(s.clock.Now()), 0), func() { b.fire(se) })
+	return se, nil
+}
+
+// fire ถูกเรียกจาก timer เมื่อถึงเวลา
+func (b *memoryBus) fire(se *scheduledEvent) {
+	s := b.sched
+	if !s.take(se) {
+		return
+	}
+	defer s.firing.Done()
+	se.ev.Time = s.clock.Now()
+	_, err := b.publish(se.ctx, se.ev)
+	se.finish(err)
+}
+
+// closeScheduled จัดการ event ที่ยังไม่ถึงเวลาตาม Options.ScheduleOnClose (เรียกจาก Close ก่อนเริ่ม drain)
+func (b *memoryBus) closeScheduled(ctx context.Context) {
+	for _, se := range b.sched.shutdown() {
+		if b.opts.ScheduleOnClose != ScheduleFlush {
+			se.finish(ErrClosed)
+			continue
+		}
+		se.ev.Time = b.sched.clock.Now()
+		_, err := b.publish(ctx, se.ev)
+		se.finish(err)
+	}
+}
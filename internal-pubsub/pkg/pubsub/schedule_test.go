@@ -0,0 +1,142 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock เดินเวลาเมื่อเรียก Advance เท่านั้น และเรียก timer ที่ถึงเวลาใน goroutine ของผู้เรียก
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	keep := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.at.After(c.now) {
+			due = append(due, t)
+		} else {
+			keep = append(keep, t)
+		}
+	}
+	c.timers = keep
+	c.mu.Unlock()
+	for _, t := range due {
+		t.f()
+	}
+}
+
+func TestPublishAfterUsesClock(t *testing.T) {
+	clock := newFakeClock()
+	bus := New(Options{Clock: clock})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.reminder", 4)
+	s := bus.(Scheduler)
+	remind, err := s.PublishAfter(WithCorrelationID(context.Background(), "corr-1"), 15*time.Minute, "order.reminder", "ORD-1")
+	if err != nil {
+		t.Fatalf("PublishAfter: %v", err)
+	}
+	paid, _ := s.PublishAt(context.Background(), clock.Now().Add(10*time.Minute), "order.reminder", "ORD-2")
+
+	if !paid.Cancel() || paid.Err() != ErrScheduleCanceled {
+		t.Fatalf("cancel: err=%v", paid.Err())
+	}
+	clock.Advance(14 * time.Minute)
+	select {
+	case ev := <-sub.C():
+		t.Fatalf("event published early: %+v", ev)
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	if err := remind.Err(); err != nil {
+		t.Fatalf("scheduled publish: %v", err)
+	}
+	select {
+	case ev := <-sub.C():
+		if ev.ID != remind.ID() || ev.Data != "ORD-1" || ev.CorrelationID() != "corr-1" ||
+			!ev.Time.Equal(remind.At()) {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	default:
+		t.Fatal("expected scheduled event")
+	}
+	if remind.Cancel() {
+		t.Fatal("cancel after publish should report false")
+	}
+}
+
+func TestCloseSchedulePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy SchedulePolicy
+		want   error
+	}{
+		{ScheduleDiscard, ErrClosed},
+		{ScheduleFlush, nil},
+	} {
+		clock := newFakeClock()
+		bus := New(Options{Clock: clock, ScheduleOnClose: tc.policy})
+		sub := bus.Subscribe("order.reminder", 4)
+
+		h, err := bus.(Scheduler).PublishAfter(context.Background(), time.Hour, "order.reminder", "ORD-1")
+		if err != nil {
+			t.Fatalf("PublishAfter: %v", err)
+		}
+		go func() { <-sub.C() }()
+		if err := bus.Close(context.Background()); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if err := h.Err(); !errors.Is(err, tc.want) {
+			t.Fatalf("policy %d: err=%v want %v", tc.policy, err, tc.want)
+		}
+		if _, err := bus.(Scheduler).PublishAfter(context.Background(), time.Second, "x", 1); err != ErrClosed {
+			t.Fatalf("schedule after close: %v", err)
+		}
+	}
+}
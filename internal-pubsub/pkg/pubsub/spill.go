@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultSpillMaxBytes เพดานข้อมูลบนดิสก์ต่อ subscription เมื่อไม่ได้กำหนด SpillOptions.MaxBytes
+const defaultSpillMaxBytes = 64 << 20
+
+// SpillOptions ตั้งค่าคิวบนดิสก์ของโหมด DeliverySpill (หนึ่งไฟล์ต่อ subscription สร้างเมื่อเริ่มล้น)
+type SpillOptions struct {
+	Dir       string       // โฟลเดอร์ของไฟล์คิว (ว่าง = os.TempDir())
+	MaxBytes  int64        // ข้อมูลที่ค้างบนดิสก์สูงสุดต่อ subscription (<= 0 ใช้ 64MiB) ไฟล์อาจโตถึงราว 2 เท่าก่อนถูกบีบ
+	MaxEvents int          // event ที่ค้างบนดิสก์สูงสุดต่อ subscription (<= 0 ไม่จำกัด)
+	Codec     PayloadCodec // แปลง Event.Data (nil = DefaultRegistry)
+}
+
+// errSpillFull คิวบนดิสก์เต็มตาม MaxBytes/MaxEvents
+var errSpillFull = errors.New("pubsub: spill queue is full")
+
+// spillQueue คิว FIFO บนไฟล์เดียว: [len uint32][gob EncodedEvent] ต่อกันไป
+// อ่านจาก readOff เขียนที่ writeOff ไฟล์ถูก truncate เมื่ออ่านหมด และถูกบีบเมื่อส่วนที่อ่านแล้วโตเกิน MaxBytes
+// ผู้เรียกต้องป้องกันการเข้าถึงพร้อมกันเอง (memSub.mu)
+type spillQueue struct {
+	opts SpillOptions
+
+	f        *os.File // nil จนกว่าจะล้นครั้งแรก
+	readOff  int64
+	writeOff int64
+	count    int
+	err      error // error ถาวรของไฟล์ (เช่นสร้างไม่ได้)
+}
+
+func newSpillQueue(opts SpillOptions) *spillQueue {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultSpillMaxBytes
+	}
+	if opts.Codec == nil {
+		opts.Codec = DefaultRegistry
+	}
+	return &spillQueue{opts: opts}
+}
+
+func (q *spillQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	return q.count
+}
+
+func (q *spillQueue) bytes() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.writeOff - q.readOff
+}
+
+// push ต่อท้าย event คืน errSpillFull เมื่อเกินเพดาน หรือ error จาก codec/ไฟล์
+func (q *spillQueue) push(ev Event) error {
+	if q.err != nil {
+		return q.err
+	}
+	rec, err := EncodeEvent(q.opts.Codec, ev)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(&rec); err != nil {
+		return fmt.Errorf("pubsub: spill encode topic=%s: %w", ev.Topic, err)
+	}
+	frame := buf.Bytes()
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+
+	if q.bytes()+int64(len(frame)) > q.opts.MaxBytes ||
+		(q.opts.MaxEvents > 0 && q.count >= q.opts.MaxEvents) {
+		return errSpillFull
+	}
+	if q.f == nil {
+		if q.f, err = os.CreateTemp(q.opts.Dir, "pubsub-spill-*.log"); err != nil {
+			q.err = fmt.Errorf("pubsub: spill file: %w", err)
+			return q.err
+		}
+	} else if q.readOff >= q.opts.MaxBytes {
+		if err := q.compact(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.f.WriteAt(frame, q.writeOff); err != nil {
+		return fmt.Errorf("pubsub: spill write: %w", err)
+	}
+	q.writeOff += int64(len(frame))
+	q.count++
+	return nil
+}
+
+// peek อ่าน event ที่เก่าที่สุดโดยยังไม่นำออก คืนขนาด frame สำหรับ pop
+// ถ้า decode Data ไม่ได้จะคืน event (Data == nil) พร้อม error เพื่อให้ผู้เรียกนับเป็น drop แล้ว pop ทิ้ง
+func (q *spillQueue) peek() (Event, int64, error) {
+	var hdr [4]byte
+	if _, err := q.f.ReadAt(hdr[:], q.readOff); err != nil {
+		return Event{}, 0, q.corrupt(err)
+	}
+	n := int64(binary.BigEndian.Uint32(hdr[:]))
+	if q.readOff+4+n > q.writeOff {
+		return Event{}, 0, q.corrupt(io.ErrUnexpectedEOF)
+	}
+	payload := make([]byte, n)
+	if _, err := q.f.ReadAt(payload, q.readOff+4); err != nil {
+		return Event{}, 0, q.corrupt(err)
+	}
+	var rec EncodedEvent
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return Event{}, 4 + n, &DecodeError{Err: err}
+	}
+	ev, err := rec.Decode(q.opts.Codec)
+	return ev, 4 + n, err
+}
+
+// corrupt อ่านไฟล์ต่อไม่ได้: ทิ้งทุกอย่างที่ค้างบนดิสก์
+func (q *spillQueue) corrupt(err error) error {
+	q.readOff = q.writeOff
+	q.count = 0
+	q.reset()
+	return fmt.Errorf("pubsub: spill read: %w", err)
+}
+
+// pop นำ event ที่ peek ไว้ออก
+func (q *spillQueue) pop(size int64) {
+	q.readOff += size
+	q.count--
+	if q.count <= 0 {
+		q.reset()
+	}
+}
+
+// reset คืนพื้นที่ดิสก์เมื่อคิวว่าง
+func (q *spillQueue) reset() {
+	q.count = 0
+	q.readOff, q.writeOff = 0, 0
+	if q.f != nil {
+		_ = q.f.Truncate(0)
+	}
+}
+
+// compact ย้ายส่วนที่ยังไม่อ่านไปไว้ต้นไฟล์ (คัดลอกไปข้างหน้าทีละก้อน ปลอดภัยแม้ช่วงทับกัน)
+func (q *spillQueue) compact() error {
+	buf := make([]byte, 64<<10)
+	var dst int64
+	for src := q.readOff; src < q.writeOff; {
+		n, err := q.f.ReadAt(buf[:min(int64(len(buf)), q.writeOff-src)], src)
+		if err != nil && (!errors.Is(err, io.EOF) || n == 0) {
+			return fmt.Errorf("pubsub: spill compact: %w", err)
+		}
+		if _, err := q.f.WriteAt(buf[:n], dst); err != nil {
+			return fmt.Errorf("pubsub: spill compact: %w", err)
+		}
+		src += int64(n)
+		dst += int64(n)
+	}
+	q.readOff, q.writeOff = 0, dst
+	if err := q.f.Truncate(dst); err != nil {
+		return fmt.Errorf("pubsub: spill compact: %w", err)
+	}
+	return nil
+}
+
+// close ลบไฟล์ คืนจำนวน event ที่ค้างอยู่
+func (q *spillQueue) close() int {
+	if q == nil {
+		return 0
+	}
+	left := q.count
+	if q.f != nil {
+		name := q.f.Name()
+		_ = q.f.Close()
+		_ = os.Remove(name)
+		q.f = nil
+	}
+	q.count = 0
+	return left
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upperCodec เก็บ payload string เป็นตัวพิมพ์ใหญ่ เพื่อยืนยันว่า event ผ่าน codec จริง
+type upperCodec struct{}
+
+func (upperCodec) Encode(_ Topic, data any) ([]byte, error) {
+	s, ok := data.(string)
+	if !ok {
+		return nil, errors.New("not a string")
+	}
+	return []byte(strings.ToUpper(s)), nil
+}
+
+func (upperCodec) Decode(_ Topic, b []byte) (any, error) { return string(b), nil }
+
+func TestSpillFeedsBackInOrder(t *testing.T) {
+	dir := t.TempDir()
+	bus := New(Options{Spill: SpillOptions{Dir: dir}})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("order.created", 1, WithDeliveryMode(DeliverySpill))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := range 50 {
+		if err := bus.Publish(ctx, "order.created", i); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one spill file, got %d", len(files))
+	}
+
+	for i := range 50 {
+		select {
+		case ev := <-sub.C():
+			if ev.Data != i {
+				t.Fatalf("out of order: got %v want %d", ev.Data, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing event %d", i)
+		}
+	}
+	st := bus.(StatsReporter).Stats().Subscriptions[0]
+	if st.Spilled != 0 || st.SpillBytes != 0 || st.Delivered != 50 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestSpillLimitsAndCodec(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reasons []DropReason
+	)
+	bus := New(Options{
+		Spill: SpillOptions{Dir: t.TempDir(), MaxEvents: 2, Codec: upperCodec{}},
+		OnDrop: func(_ Topic, _ Event, r DropReason) {
+			mu.Lock()
+			reasons = append(reasons, r)
+			mu.Unlock()
+		},
+	})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("mail.send", 1, WithDeliveryMode(DeliverySpill))
+	rp := bus.(ReportingPublisher)
+	for _, data := range []any{"a", "b", 42, "c", "d"} {
+		_, _ = rp.PublishWithReport(context.Background(), "mail.send", data)
+	}
+
+	var got []any
+	for range 3 {
+		select {
+		case ev := <-sub.C():
+			got = append(got, ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("got only %v", got)
+		}
+	}
+	if got[0] != "a" || got[1] != "B" || got[2] != "C" {
+		t.Fatalf("unexpected events %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reasons) != 2 || reasons[0] != DropSpillError || reasons[1] != DropSpillFull {
+		t.Fatalf("unexpected drop reasons %v", reasons)
+	}
+}
+
+func TestSpillQueueCompactsConsumedPrefix(t *testing.T) {
+	q := newSpillQueue(SpillOptions{Dir: t.TempDir(), MaxBytes: 512})
+	defer q.close()
+
+	next, want := 0, 0
+	for range 200 {
+		// เขียนสองอ่านหนึ่ง จนคิวเกือบเต็ม แล้วอ่านทีละตัว ให้ readOff เลย MaxBytes
+		for range 2 {
+			if err := q.push(Event{Topic: "t", Data: next}); err != nil {
+				break
+			}
+			next++
+		}
+		ev, size, err := q.peek()
+		if err != nil {
+			t.Fatalf("peek: %v", err)
+		}
+		if ev.Data != want {
+			t.Fatalf("got %v want %d", ev.Data, want)
+		}
+		q.pop(size)
+		want++
+		if fi, _ := q.f.Stat(); fi.Size() > 2*q.opts.MaxBytes {
+			t.Fatalf("spill file grew to %d bytes", fi.Size())
+		}
+	}
+}
+
+// badCodec อ่าน payload "bad" กลับไม่ได้ ทำให้ dispatcher ต้องทิ้ง event นั้นผ่าน OnDrop
+type badCodec struct{}
+
+func (badCodec) Encode(_ Topic, data any) ([]byte, error) { return []byte(data.(string)), nil }
+
+func (badCodec) Decode(_ Topic, b []byte) (any, error) {
+	if string(b) == "bad" {
+		return nil, errors.New("corrupt")
+	}
+	return string(b), nil
+}
+
+func TestUnsubscribeWhileOnDropPublishes(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var bus Bus
+	bus = New(Options{
+		Spill: SpillOptions{Dir: t.TempDir(), Codec: badCodec{}},
+		OnDrop: func(_ Topic, ev Event, _ DropReason) {
+			close(entered)
+			<-release
+			_ = bus.Publish(context.Background(), "audit.dropped", ev.ID)
+		},
+	})
+	defer closeNow(bus)
+
+	sub := bus.Subscribe("mail.send", 1, WithDeliveryMode(DeliverySpill))
+	_ = bus.Publish(context.Background(), "mail.send", "ok")
+	_ = bus.Publish(context.Background(), "mail.send", "bad")
+	<-sub.C()
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher never dropped the corrupt event")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sub.Unsubscribe()
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond) // ให้ Unsubscribe ไปรอ dispatcher ก่อน
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe deadlocked with OnDrop publishing")
+	}
+}
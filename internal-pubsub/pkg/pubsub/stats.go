@@ -0,0 +1,244 @@
+package pubsub
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// DropReason บอกสาเหตุที่ event ไม่ถึงผู้รับ
+type DropReason string
+
+const (
+	// DropBufferFull: chan ผู้รับเต็มในโหมด DeliveryDrop
+	DropBufferFull DropReason = "buffer_full"
+	// DropTimeout: ส่งไม่ทันภายใน timeout ในโหมด DeliveryTimeout
+	DropTimeout DropReason = "timeout"
+	// DropSpillFull: คิวบนดิสก์ของ DeliverySpill เกินเพดานใน Options.Spill
+	DropSpillFull DropReason = "spill_full"
+	// DropSpillError: เขียนหรืออ่านคิวบนดิสก์ไม่ได้ (เช่น codec ล้มเหลว)
+	DropSpillError DropReason = "spill_error"
+	// DropMaxDeliveries: ส่งครบ MaxDeliveries ครั้งแล้วยังไม่ถูก Ack (โหมด ack)
+	DropMaxDeliveries DropReason = "max_deliveries"
+)
+
+// Counters ตัวนับผลการส่ง (ค่าสะสมตั้งแต่สร้าง bus หรือ subscription)
+type Counters struct {
+	Delivered  uint64
+	Dropped    uint64
+	TimedOut   uint64
+	Suppressed uint64 // event ซ้ำที่ถูกตัดก่อนส่ง (เฉพาะตัวนับต่อ topic)
+
+	Redelivered uint64 // event ที่ถูกส่งใหม่เพราะ Nack หรือหมด visibility (โหมด ack)
+	Terminated  uint64 // event ที่ผู้รับ Terminate (โหมด ack)
+}
+
+// SubscriptionStats สถานะและตัวนับของ subscription หนึ่งตัว
+type SubscriptionStats struct {
+	ID    string
+	Topic Topic  // topic หรือ pattern ที่ใช้ Subscribe
+	Group string // ชื่อ consumer group (ว่าง = broadcast)
+	Mode  DeliveryMode
+
+	Capacity          int           // ความจุ chan
+	Length            int           // event ที่ค้างใน chan ตอนนี้
+	QueueCapacity     int           // ความจุคิวของ dispatcher (0 = ไม่มีคิว)
+	Queued            int           // event ที่รอ dispatcher ป้อนเข้า chan
+	Spilled           int           // event ที่ค้างบนดิสก์ (เฉพาะ DeliverySpill)
+	SpillBytes        int64         // ขนาดข้อมูลที่ค้างบนดิสก์
+	Pending           int           // event ที่ยังไม่ MarkDone (เฉพาะ WithDoneTracking)
+	Unacked           int           // event ที่ส่งแล้วแต่ยังไม่ Ack รวมที่รอส่งใหม่หลัง Nack (เฉพาะ WithAck)
+	BlockedPublishers int           // Publish ที่กำลังรอเพราะ chan เต็ม
+	LastReceive       time.Time     // เวลาที่ event ล่าสุดเข้า chan ของผู้รับ ไม่นับที่ยังรอในคิวหรือบนดิสก์ (zero = ยังไม่เคย)
+	SinceLastReceive  time.Duration // นับจาก LastReceive หรือจากตอน Subscribe ถ้ายังไม่เคยได้รับ
+
+	Counters
+}
+
+// TopicInfo สรุป topic (หรือ pattern) ที่มีผู้สมัครอยู่
+type TopicInfo struct {
+	Topic       Topic
+	Pattern     bool
+	Subscribers int
+	Groups      []string // ชื่อ consumer group ที่สมัคร topic นี้
+}
+
+// Inspector implement โดย Bus ที่ให้ดูสถานะภายในแบบอ่านอย่างเดียว (เช่น bus จาก New)
+type Inspector interface {
+	StatsReporter
+	Topics() []TopicInfo
+}
+
+// Stats ภาพรวมตัวนับของ bus ณ เวลาที่เรียก
+type Stats struct {
+	Topics        map[Topic]Counters // แยกตาม topic ที่ publish
+	Subscriptions []SubscriptionStats
+	Dedup         DedupStats // ศูนย์ทั้งหมดถ้าไม่ได้เปิด Options.Dedup
+}
+
+// StatsReporter implement โดย Bus ที่รายงานตัวนับได้ (เช่น bus จาก New)
+type StatsReporter interface {
+	Stats() Stats
+}
+
+type counters struct {
+	delivered   atomic.Uint64
+	dropped     atomic.Uint64
+	timedOut    atomic.Uint64
+	suppressed  atomic.Uint64
+	redelivered atomic.Uint64
+	terminated  atomic.Uint64
+}
+
+func (c *counters) snapshot() Counters {
+	return Counters{
+		Delivered:  c.delivered.Load(),
+		Dropped:    c.dropped.Load(),
+		TimedOut:   c.timedOut.Load(),
+		Suppressed: c.suppressed.Load(),
+
+		Redelivered: c.redelivered.Load(),
+		Terminated:  c.terminated.Load(),
+	}
+}
+
+// deliveryOutcome ผลของการส่งให้ subscription หนึ่งตัว
+type deliveryOutcome int
+
+const (
+	outcomeDelivered deliveryOutcome = iota
+	outcomeDropped
+	outcomeTimedOut
+)
+
+func (b *memoryBus) topicCounters(topic Topic) *counters {
+	v, _ := b.topicStats.LoadOrStore(topic, &counters{})
+	return v.(*counters)
+}
+
+// record นับผลการส่งและเรียก OnDrop เมื่อ event หาย
+func (b *memoryBus) record(s *memSub, ev Event, o deliveryOutcome) {
+	tc := b.topicCounters(ev.Topic)
+	switch o {
+	case outcomeDelivered:
+		tc.delivered.Add(1)
+		s.stats.delivered.Add(1)
+	case outcomeDropped:
+		tc.dropped.Add(1)
+		s.stats.dropped.Add(1)
+		if b.opts.OnDrop != nil {
+			b.opts.OnDrop(ev.Topic, ev, DropBufferFull)
+		}
+	case outcomeTimedOut:
+		tc.timedOut.Add(1)
+		s.stats.timedOut.Add(1)
+		if b.opts.OnDrop != nil {
+			b.opts.OnDrop(ev.Topic, ev, DropTimeout)
+		}
+	}
+}
+
+// received บันทึกเวลาที่ event เข้า chan จริง (ไม่นับตอนที่เพิ่งเข้าคิวหรือลงดิสก์)
+func (s *memSub) received() {
+	s.lastReceive.Store(time.Now().UnixNano())
+}
+
+// recordSpillDrop นับ event ที่หายเพราะคิวบนดิสก์
+func (b *memoryBus) recordSpillDrop(s *memSub, ev Event, err error) {
+	b.topicCounters(ev.Topic).dropped.Add(1)
+	s.stats.dropped.Add(1)
+	if b.opts.OnDrop != nil {
+		reason := DropSpillError
+		if errors.Is(err, errSpillFull) {
+			reason = DropSpillFull
+		}
+		b.opts.OnDrop(ev.Topic, ev, reason)
+	}
+}
+
+// recordAckDrop นับ event ที่หายเพราะส่งครบ MaxDeliveries แล้ว
+func (b *memoryBus) recordAckDrop(s *memSub, ev Event) {
+	b.topicCounters(ev.Topic).dropped.Add(1)
+	s.stats.dropped.Add(1)
+	if b.opts.OnDrop != nil {
+		b.opts.OnDrop(ev.Topic, ev, DropMaxDeliveries)
+	}
+}
+
+// Stats คืนตัวนับต่อ topic และสถานะของทุก subscription ที่ยัง active
+func (b *memoryBus) Stats() Stats {
+	st := Stats{Topics: make(map[Topic]Counters), Dedup: b.dedup.stats()}
+	b.topicStats.Range(func(k, v any) bool {
+		st.Topics[k.(Topic)] = v.(*counters).snapshot()
+		return true
+	})
+
+	now := time.Now()
+	b.mu.RLock()
+	for _, index := range []map[Topic]map[*memSub]struct{}{b.topics, b.patterns} {
+		for _, set := range index {
+			for s := range set {
+				st.Subscriptions = append(st.Subscriptions, s.snapshot(now))
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	slices.SortFunc(st.Subscriptions, func(a, b SubscriptionStats) int {
+		if c := cmp.Compare(a.Topic, b.Topic); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return st
+}
+
+// Topics คืน topic และ pattern ทั้งหมดที่มีผู้สมัคร เรียงตามชื่อ
+func (b *memoryBus) Topics() []TopicInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var out []TopicInfo
+	for _, index := range []map[Topic]map[*memSub]struct{}{b.topics, b.patterns} {
+		for topic, set := range index {
+			info := TopicInfo{Topic: topic, Pattern: topic.IsPattern(), Subscribers: len(set)}
+			for key := range b.groups {
+				if key.topic == topic {
+					info.Groups = append(info.Groups, key.name)
+				}
+			}
+			slices.Sort(info.Groups)
+			out = append(out, info)
+		}
+	}
+	slices.SortFunc(out, func(a, b TopicInfo) int { return cmp.Compare(a.Topic, b.Topic) })
+	return out
+}
+
+func (s *memSub) snapshot(now time.Time) SubscriptionStats {
+	st := SubscriptionStats{
+		ID: s.id, Topic: s.topic, Group: s.group, Mode: s.mode,
+		Capacity:          cap(s.ch),
+		Length:            len(s.ch),
+		QueueCapacity:     s.queueCap,
+		BlockedPublishers: int(s.waiting.Load()),
+		Counters:          s.stats.snapshot(),
+	}
+	if s.tracked {
+		st.Pending = int(s.pending.Load())
+	}
+	s.mu.Lock()
+	st.Queued = len(s.queue)
+	st.Spilled = s.spill.len()
+	st.SpillBytes = s.spill.bytes()
+	st.Unacked = s.ack.outstanding()
+	s.mu.Unlock()
+	since := s.created
+	if last := s.lastReceive.Load(); last != 0 {
+		st.LastReceive = time.Unix(0, last)
+		since = st.LastReceive
+	}
+	st.SinceLastReceive = now.Sub(since)
+	return st
+}
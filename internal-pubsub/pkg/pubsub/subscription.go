@@ -1,6 +1,10 @@
 package pubsub
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Subscription ให้ฝั่งผู้รับใช้ดึงอีเวนต์ผ่าน C และยกเลิกด้วย Unsubscribe
 type Subscription interface {
@@ -8,12 +12,45 @@ type Subscription interface {
 	Unsubscribe()
 }
 
+// DoneMarker implement โดย Subscription ที่ติดตามการประมวลผลได้ (สมัครด้วย WithDoneTracking)
+// ผู้รับเรียก MarkDone พร้อม event ตัวที่ประมวลผลเสร็จ (ลำดับใดก็ได้ เช่นจาก worker หลายตัว)
+// เพื่อให้ Close รู้ว่างานค้างหมดแล้ว และ backend ที่เก็บถาวรยืนยันได้ถูกตัว
+type DoneMarker interface {
+	MarkDone(ev Event)
+}
+
 type memSub struct {
-	bus    *memoryBus
-	topic  Topic
-	ch     chan Event
-	closed bool
-	once   sync.Once
+	id      string
+	bus     *memoryBus
+	topic   Topic
+	pattern bool // true ถ้า topic มี wildcard
+	mode    DeliveryMode
+	timeout time.Duration
+	ch      chan Event
+	closed  bool // ถูกถอดออกจาก bus แล้ว (ป้องกันด้วย bus.mu)
+	once    sync.Once
+	stats   counters
+	group   string       // ชื่อ consumer group (ว่าง = รับแบบ broadcast)
+	tracked bool         // ผู้รับจะเรียก MarkDone
+	pending atomic.Int64 // event ที่ส่งแล้วแต่ยังไม่ MarkDone (เฉพาะ tracked)
+
+	created     time.Time
+	lastReceive atomic.Int64 // unix nano ของ event ล่าสุดที่เข้า chan จริง (ไม่ใช่ตอนเข้าคิว)
+	waiting     atomic.Int64 // Publish ที่กำลังรอเพราะ chan เต็ม
+
+	// คิวของ dispatcher (ใช้เมื่อ queueCap > 0): รับ event ที่ล้นจาก chan แล้วป้อนเข้า chan ตามลำดับ
+	queueCap int
+	mu       sync.Mutex // ป้องกัน queue, space, stopped, spill, ack และการส่งแบบไม่บล็อกเข้า ch
+	queue    []Event
+	space    chan struct{} // ถูกปิดทุกครั้งที่ queue มีที่ว่างเพิ่ม
+	wake     chan struct{} // ปลุก dispatcher เมื่อมี event เข้าคิว
+	stopped  bool          // ch ถูกปิดแล้ว
+	spill    *spillQueue   // คิวบนดิสก์ของ DeliverySpill (nil = ไม่ใช้)
+	ack      *ackTracker   // event ที่รอ Ack (nil = ไม่ได้ใช้ WithAck)
+
+	done           chan struct{} // ปิดเมื่อเริ่มหยุด subscription
+	dispatcherDone chan struct{} // ปิดเมื่อ dispatcher จบ (nil ถ้าไม่มี dispatcher)
+	sendMu         sync.RWMutex  // ผู้ส่งแบบบล็อกถือ RLock, การปิด ch ถือ Lock
 }
 
 func (s *memSub) C() <-chan Event { return s.ch }
@@ -21,25 +58,81 @@ func (s *memSub) C() <-chan Event { return s.ch }
 func (s *memSub) Unsubscribe() {
 	s.once.Do(func() {
 		s.bus.mu.Lock()
-		defer s.bus.mu.Unlock()
 		if s.closed {
+			s.bus.mu.Unlock()
 			return
 		}
-		if subs, ok := s.bus.topics[s.topic]; ok {
+		index := s.bus.index(s)
+		if subs, ok := index[s.topic]; ok {
 			delete(subs, s)
 			if len(subs) == 0 {
-				delete(s.bus.topics, s.topic)
+				delete(index, s.topic)
 			}
 		}
-		close(s.ch)
-		s.closed = true
+		if s.group != "" {
+			key := groupKey{topic: s.topic, name: s.group}
+			if g, ok := s.bus.groups[key]; ok {
+				g.remove(s)
+				if len(g.members) == 0 {
+					delete(s.bus.groups, key)
+				}
+			}
+		}
+		s.detachLocked()
+		s.bus.mu.Unlock()
+		s.finish()
 	})
 }
 
-func (s *memSub) closeNoLock() {
+// MarkDone ของ memory bus นับจำนวนอย่างเดียว จึงไม่ใช้ ev
+func (s *memSub) MarkDone(Event) {
+	if s.pending.Add(-1) < 0 {
+		s.pending.Store(0)
+	}
+	s.bus.settle()
+}
+
+// backlog จำนวน event ที่ยังไม่เสร็จ: โหมด ack นับถึงตอนถูกตัดสิน, tracked นับถึงตอน MarkDone,
+// นอกนั้นนับเฉพาะที่ค้างใน chan คิว และดิสก์
+func (s *memSub) backlog() int {
+	if s.ack != nil {
+		s.mu.Lock()
+		outstanding := s.ack.outstanding()
+		s.mu.Unlock()
+		return s.load() + outstanding
+	}
+	if s.tracked {
+		return int(s.pending.Load())
+	}
+	return s.load()
+}
+
+// detachLocked ทำเครื่องหมายว่าถูกถอดออกจาก bus และสั่งให้ dispatcher กับ Publish ที่รออยู่หยุด
+// (ต้องถือ bus.mu อยู่) แล้วต้องเรียก finish หลังปล่อย bus.mu
+func (s *memSub) detachLocked() {
 	if s.closed {
 		return
 	}
-	close(s.ch)
 	s.closed = true
+	close(s.done)
+}
+
+// finish รอ dispatcher จบแล้วปิด chan ห้ามถือ bus.mu เพราะ dispatcher อาจเรียก OnDrop
+// ซึ่งเป็นโค้ดของผู้ใช้ที่ Publish ต่อได้
+func (s *memSub) finish() {
+	if s.dispatcherDone != nil {
+		<-s.dispatcherDone
+	}
+	s.sendMu.Lock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.sendMu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.queue = nil
+	s.spill.close()
+	s.ack.stop()
+	close(s.ch)
 }
@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"cmp"
+	"strings"
+)
+
+const (
+	// TopicSeparator ตัวคั่นระดับของ topic เช่น "order.created"
+	TopicSeparator = "."
+	// WildcardOne แทนหนึ่งระดับพอดี เช่น "order.*" ตรงกับ "order.created"
+	WildcardOne = "*"
+	// WildcardMany แทนศูนย์ระดับขึ้นไป เช่น "order.#" ตรงกับ "order" และ "order.item.added"
+	WildcardMany = "#"
+)
+
+// IsPattern บอกว่า topic นี้มี wildcard (ใช้ได้เฉพาะตอน Subscribe)
+func (t Topic) IsPattern() bool {
+	for _, seg := range strings.Split(string(t), TopicSeparator) {
+		if seg == WildcardOne || seg == WildcardMany {
+			return true
+		}
+	}
+	return false
+}
+
+// Match ตรวจว่า topic ตรงกับ pattern หรือไม่ (pattern ที่ไม่มี wildcard จะเทียบตรงตัว)
+func Match(pattern, topic Topic) bool {
+	if !pattern.IsPattern() {
+		return pattern == topic
+	}
+	return matchSegments(
+		strings.Split(string(pattern), TopicSeparator),
+		strings.Split(string(topic), TopicSeparator),
+	)
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		switch pat[0] {
+		case WildcardMany:
+			// "#" ท้ายสุดกินที่เหลือทั้งหมด
+			if len(pat) == 1 {
+				return true
+			}
+			// ลองให้ "#" กิน 0..n ระดับ
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		case WildcardOne:
+			if len(segs) == 0 {
+				return false
+			}
+		default:
+			if len(segs) == 0 || pat[0] != segs[0] {
+				return false
+			}
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// comparePatterns เรียง pattern จากเจาะจงที่สุดไปกว้างที่สุด ใช้ตัดสินเมื่อหลาย pattern ตรงกับ topic เดียวกัน
+// เจาะจงกว่าคือมีระดับที่เป็นคำจริงมากกว่า แล้ว "#" น้อยกว่า แล้วมีระดับมากกว่า ที่เหลือเรียงตามตัวอักษร
+func comparePatterns(a, b Topic) int {
+	litA, manyA, segsA := patternShape(a)
+	litB, manyB, segsB := patternShape(b)
+	return cmp.Or(
+		cmp.Compare(litB, litA),
+		cmp.Compare(manyA, manyB),
+		cmp.Compare(segsB, segsA),
+		cmp.Compare(a, b),
+	)
+}
+
+func patternShape(t Topic) (literal, many, segs int) {
+	for _, seg := range strings.Split(string(t), TopicSeparator) {
+		switch seg {
+		case WildcardMany:
+			many++
+		case WildcardOne:
+		default:
+			literal++
+		}
+		segs++
+	}
+	return literal, many, segs
+}
@@ -0,0 +1,30 @@
+package pubsub
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern Topic
+		topic   Topic
+		want    bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.paid", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order", false},
+		{"order.*", "order.item.added", false},
+		{"*.created", "order.created", true},
+		{"order.#", "order", true},
+		{"order.#", "order.item.added", true},
+		{"#", "anything.at.all", true},
+		{"order.#.added", "order.added", true},
+		{"order.#.added", "order.item.added", true},
+		{"order.#.added", "order.item.removed", false},
+		{"invoice.#", "order.created", false},
+	}
+	for _, c := range cases {
+		if got := Match(c.pattern, c.topic); got != c.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", c.pattern, c.topic, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TypedTopic ผูก topic เข้ากับชนิดของ payload เพื่อให้ publish/subscribe แบบ type-safe
+// ทำงานบน Bus เดิม จึงใช้กับ implementation ใดก็ได้
+type TypedTopic[T any] struct {
+	bus   Bus
+	topic Topic
+}
+
+// NewTypedTopic สร้าง TypedTopic บน bus ที่กำหนด
+func NewTypedTopic[T any](bus Bus, topic Topic) TypedTopic[T] {
+	return TypedTopic[T]{bus: bus, topic: topic}
+}
+
+// Topic คืนชื่อ topic
+func (t TypedTopic[T]) Topic() Topic { return t.topic }
+
+// Publish ส่ง payload ชนิด T
+func (t TypedTopic[T]) Publish(ctx context.Context, data T) error {
+	return t.bus.Publish(ctx, t.topic, data)
+}
+
+// Subscribe สมัครรับอีเวนต์ที่แปลงเป็น T แล้ว (buffer <= 0 ใช้ค่า default ของ bus)
+func (t TypedTopic[T]) Subscribe(buffer int, opts ...SubscribeOption) TypedSubscription[T] {
+	raw := t.bus.Subscribe(t.topic, buffer, opts...)
+	sub := &typedSub[T]{raw: raw, ch: make(chan TypedEvent[T]), done: make(chan struct{})}
+	go sub.loop()
+	return sub
+}
+
+// TypedEvent คืออีเวนต์ที่ payload ถูกแปลงเป็น T แล้ว
+// ถ้า payload ไม่ใช่ T จะได้ Err เป็น *TypeMismatchError และ Data เป็น zero value
+type TypedEvent[T any] struct {
+	ID      string
+	Topic   Topic
+	Data    T
+	Time    time.Time
+	Headers map[string]string
+	Attempt int // ครั้งที่ส่งซ้ำ (โหมด ack) ดู Event.Attempt
+	Err     error
+
+	raw Event
+}
+
+// Event คืน event ดิบที่ได้จาก bus ใช้ส่งให้ DoneMarker.MarkDone หรือ Acker
+func (e TypedEvent[T]) Event() Event { return e.raw }
+
+// TypedSubscription เหมือน Subscription แต่ส่ง TypedEvent[T]
+type TypedSubscription[T any] interface {
+	C() <-chan TypedEvent[T]
+	Unsubscribe()
+}
+
+type typedSub[T any] struct {
+	raw  Subscription
+	ch   chan TypedEvent[T]
+	done chan struct{}
+	once sync.Once
+}
+
+func (s *typedSub[T]) C() <-chan TypedEvent[T] { return s.ch }
+
+func (s *typedSub[T]) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.raw.Unsubscribe()
+	})
+}
+
+// MarkDone ส่งต่อไปยัง subscription ดิบ (ถ้ารองรับ) ev ได้จาก TypedEvent.Event
+func (s *typedSub[T]) MarkDone(ev Event) {
+	if m, ok := s.raw.(DoneMarker); ok {
+		m.MarkDone(ev)
+	}
+}
+
+// Ack, Nack และ Terminate ส่งต่อไปยัง subscription ดิบ (ถ้ารองรับ Acker) ev ได้จาก TypedEvent.Event
+func (s *typedSub[T]) Ack(ev Event) {
+	if a, ok := s.raw.(Acker); ok {
+		a.Ack(ev)
+	}
+}
+
+func (s *typedSub[T]) Nack(ev Event, delay time.Duration) {
+	if a, ok := s.raw.(Acker); ok {
+		a.Nack(ev, delay)
+	}
+}
+
+func (s *typedSub[T]) Terminate(ev Event) {
+	if a, ok := s.raw.(Acker); ok {
+		a.Terminate(ev)
+	}
+}
+
+// loop แปลงอีเวนต์จาก subscription ดิบ จนกว่า channel ดิบจะถูกปิด
+func (s *typedSub[T]) loop() {
+	defer close(s.ch)
+	for ev := range s.raw.C() {
+		data, err := DataAs[T](ev)
+		select {
+		case s.ch <- TypedEvent[T]{
+			ID: ev.ID, Topic: ev.Topic, Data: data,
+			Time: ev.Time, Headers: ev.Headers, Attempt: ev.Attempt, Err: err,
+			raw: ev,
+		}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// DataAs แปลง ev.Data เป็น T โดยคืน *TypeMismatchError แทนการ panic
+func DataAs[T any](ev Event) (T, error) {
+	data, ok := ev.Data.(T)
+	if !ok {
+		var zero T
+		return zero, &TypeMismatchError{
+			Topic: ev.Topic,
+			Want:  reflect.TypeFor[T]().String(),
+			Got:   fmt.Sprintf("%T", ev.Data),
+		}
+	}
+	return data, nil
+}
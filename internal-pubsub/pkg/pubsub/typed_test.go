@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type order struct{ ID string }
+
+func TestTypedTopicReportsMismatch(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	orders := NewTypedTopic[order](bus, "order.created")
+	sub := orders.Subscribe(4)
+	defer sub.Unsubscribe()
+
+	if err := orders.Publish(context.Background(), order{ID: "ORD-1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := bus.Publish(context.Background(), "order.created", "not an order"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	ev := <-sub.C()
+	if ev.Err != nil || ev.Data.ID != "ORD-1" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	ev = <-sub.C()
+	if !errors.Is(ev.Err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", ev.Err)
+	}
+	var mismatch *TypeMismatchError
+	if !errors.As(ev.Err, &mismatch) || mismatch.Got != "string" {
+		t.Fatalf("unexpected error detail %v", ev.Err)
+	}
+}
+
+func TestTypedSubscriptionForwardsAck(t *testing.T) {
+	bus := New(DefaultOptions())
+	defer closeNow(bus)
+
+	orders := NewTypedTopic[order](bus, "order.created")
+	sub := orders.Subscribe(4, WithAck(time.Minute))
+	defer sub.Unsubscribe()
+	acker, ok := sub.(Acker)
+	if !ok {
+		t.Fatal("typed subscription does not implement Acker")
+	}
+
+	_ = orders.Publish(context.Background(), order{ID: "ORD-1"})
+	first := <-sub.C()
+	acker.Nack(first.Event(), 0)
+
+	ev := <-sub.C()
+	if ev.Data.ID != "ORD-1" || ev.Attempt != first.Attempt+1 {
+		t.Fatalf("redelivered %+v after attempt %d", ev, first.Attempt)
+	}
+	acker.Ack(ev.Event())
+	if st := bus.(StatsReporter).Stats().Subscriptions[0]; st.Unacked != 0 || st.Redelivered != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
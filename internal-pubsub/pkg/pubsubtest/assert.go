@@ -0,0 +1,90 @@
+package pubsubtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Matcher ตรวจว่า event ตรงกับที่ test คาดไว้หรือไม่
+type Matcher func(ev pubsub.Event) bool
+
+// Any ตรงกับทุก event
+func Any() Matcher {
+	return func(pubsub.Event) bool { return true }
+}
+
+// DataEqual ตรงเมื่อ payload เท่ากับ want (เทียบด้วย reflect.DeepEqual)
+func DataEqual(want any) Matcher {
+	return func(ev pubsub.Event) bool { return reflect.DeepEqual(ev.Data, want) }
+}
+
+// Data ตรงเมื่อ payload เป็นชนิด T และ fn คืน true
+func Data[T any](fn func(T) bool) Matcher {
+	return func(ev pubsub.Event) bool {
+		data, err := pubsub.DataAs[T](ev)
+		return err == nil && fn(data)
+	}
+}
+
+// Header ตรงเมื่อ header key มีค่าเป็น value
+func Header(key, value string) Matcher {
+	return func(ev pubsub.Event) bool { return ev.Headers[key] == value }
+}
+
+// CausedBy ตรงเมื่อ event ถูก publish จาก handler ของ parent
+func CausedBy(parent pubsub.Event) Matcher {
+	return func(ev pubsub.Event) bool { return ev.CausationID() == parent.ID }
+}
+
+func matchAll(ev pubsub.Event, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if m != nil && !m(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertPublished ทำให้ test ล้มทันทีถ้ายังไม่มี event บน topic (เป็น pattern ได้) ที่ตรงกับทุก matcher
+// คืน event แรกที่ตรงเพื่อใช้ตรวจต่อ ไม่รอ event ที่ยังไม่ถูก publish (ใช้ WaitForEvent กับ handler แบบ async)
+func (b *Bus) AssertPublished(t testing.TB, topic pubsub.Topic, matchers ...Matcher) pubsub.Event {
+	t.Helper()
+	for _, ev := range b.Published(topic) {
+		if matchAll(ev, matchers) {
+			return ev
+		}
+	}
+	t.Fatalf("pubsubtest: no matching event published on %s; published:\n%s", topic, b.describe())
+	return pubsub.Event{}
+}
+
+// AssertNotPublished ทำให้ test ล้มถ้ามี event บน topic ที่ตรงกับทุก matcher
+func (b *Bus) AssertNotPublished(t testing.TB, topic pubsub.Topic, matchers ...Matcher) {
+	t.Helper()
+	for _, ev := range b.Published(topic) {
+		if matchAll(ev, matchers) {
+			t.Fatalf("pubsubtest: unexpected event on %s: id=%s data=%#v", ev.Topic, ev.ID, ev.Data)
+		}
+	}
+}
+
+// describe สรุปทุก Publish ที่บันทึกไว้สำหรับข้อความตอน test ล้ม
+func (b *Bus) describe() string {
+	records := b.Records()
+	if len(records) == 0 {
+		return "  (none)"
+	}
+	var sb strings.Builder
+	for _, r := range records {
+		fmt.Fprintf(&sb, "  %s data=%#v", r.Event.Topic, r.Event.Data)
+		if r.Err != nil {
+			fmt.Fprintf(&sb, " err=%v", r.Err)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,197 @@
+// Package pubsubtest มีเครื่องมือสำหรับ unit test ของ handler ที่ publish/subscribe ผ่าน pubsub.Bus
+// Bus ในแพ็กเกจนี้บันทึกทุก Publish ไว้ให้ตรวจด้วย AssertPublished และ WaitForEvent
+// และมีโหมด Synchronous ที่เรียก handler ทันทีใน goroutine ของ Publish เพื่อให้ผลแน่นอนโดยไม่ต้อง sleep
+package pubsubtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/subscriber"
+)
+
+// Options ปรับแต่ง Bus
+type Options struct {
+	// Bus ปลายทางที่ Publish/Subscribe ถูกส่งต่อไป (nil = pubsub.New(pubsub.DefaultOptions()))
+	Bus pubsub.Bus
+
+	// Synchronous ให้ handler ที่ลงทะเบียนด้วย Handle ทำงานภายใน Publish ตามลำดับที่ลงทะเบียน
+	// event ที่ handler publish ต่อจะถูกจัดการจนจบสายก่อน Publish แรกคืนค่า และ error ของ handler
+	// ถูกคืนจาก Publish (ไม่มี retry และไม่ recover panic เพื่อให้ test ล้มพร้อม stack ที่ชัดเจน)
+	// ถ้า false: handler ทำงานผ่าน subscriber.Subscriber บน goroutine ของตัวเองเหมือนของจริง
+	Synchronous bool
+}
+
+// Record คือการ Publish หนึ่งครั้งที่ Bus บันทึกไว้
+type Record struct {
+	Event pubsub.Event
+	Err   error // error จาก bus ปลายทาง (ไม่รวม error ของ handler ในโหมด Synchronous)
+}
+
+// Bus คือ pubsub.Bus ที่บันทึกทุก Publish แล้วส่งต่อให้ bus ปลายทาง
+type Bus struct {
+	inner pubsub.Bus
+	sync  bool
+
+	mu       sync.Mutex
+	records  []Record
+	changed  chan struct{} // ถูกปิดแล้วสร้างใหม่ทุกครั้งที่มี record เพิ่ม
+	handlers []handlerEntry
+
+	ctx    context.Context // ยกเลิกเมื่อ Close เพื่อหยุด handler แบบ async
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type handlerEntry struct {
+	topic   pubsub.Topic
+	handler subscriber.Handler
+}
+
+// New สร้าง Bus สำหรับ test
+func New(opts Options) *Bus {
+	if opts.Bus == nil {
+		opts.Bus = pubsub.New(pubsub.DefaultOptions())
+	}
+	b := &Bus{
+		inner:   opts.Bus,
+		sync:    opts.Synchronous,
+		changed: make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	return b
+}
+
+// NewSync สร้าง Bus ในโหมด Synchronous
+func NewSync() *Bus {
+	return New(Options{Synchronous: true})
+}
+
+// Handle ลงทะเบียน handler ของ topic (เป็น pattern ได้) ใช้ subscriber.Typed เพื่อรับ payload แบบมีชนิดได้
+// โหมด Synchronous เรียก handler ภายใน Publish ส่วนโหมดปกติเริ่ม subscriber ที่หยุดเมื่อ Close
+func (b *Bus) Handle(topic pubsub.Topic, handler subscriber.Handler, opts ...subscriber.Option) {
+	if b.sync {
+		b.mu.Lock()
+		b.handlers = append(b.handlers, handlerEntry{topic: topic, handler: handler})
+		b.mu.Unlock()
+		return
+	}
+	s := subscriber.New(b, topic, 0, opts...)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer s.Close()
+		_ = s.Run(b.ctx, handler)
+	}()
+}
+
+// Subscribe ส่งต่อให้ bus ปลายทาง
+func (b *Bus) Subscribe(topic pubsub.Topic, buffer int, opts ...pubsub.SubscribeOption) pubsub.Subscription {
+	return b.inner.Subscribe(topic, buffer, opts...)
+}
+
+// Publish บันทึก event แล้วส่งต่อให้ bus ปลายทาง (คง ID และ Headers เดียวกับที่บันทึกเมื่อปลายทางรองรับ)
+// โหมด Synchronous จะเรียก handler ที่ตรงกับ topic ต่อทันทีและคืน error ของ handler ด้วย
+func (b *Bus) Publish(ctx context.Context, topic pubsub.Topic, data any) error {
+	ev := pubsub.NewEvent(ctx, topic, data)
+	var err error
+	if ep, ok := b.inner.(pubsub.EventPublisher); ok {
+		err = ep.PublishEvent(ctx, ev)
+	} else {
+		err = b.inner.Publish(ctx, topic, data)
+	}
+	b.record(Record{Event: ev, Err: err})
+	if err != nil || !b.sync {
+		return err
+	}
+	return b.dispatch(ctx, ev)
+}
+
+// dispatch เรียก handler ของโหมด Synchronous ที่ตรงกับ event ตามลำดับที่ลงทะเบียน
+func (b *Bus) dispatch(ctx context.Context, ev pubsub.Event) error {
+	b.mu.Lock()
+	var matched []subscriber.Handler
+	for _, h := range b.handlers {
+		if pubsub.Match(h.topic, ev.Topic) {
+			matched = append(matched, h.handler)
+		}
+	}
+	b.mu.Unlock()
+
+	var errs []error
+	for _, h := range matched {
+		if err := h(pubsub.ContextWithEvent(ctx, ev), ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Bus) record(r Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, r)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Close ปิด bus ปลายทาง (รอ handler ที่ค้างตาม ctx) แล้วหยุด handler แบบ async ทั้งหมด
+// Publish หลัง Close ได้ผลเหมือน bus ปลายทาง (ปกติคือ pubsub.ErrClosed และถูกบันทึกพร้อม error)
+// สิ่งที่บันทึกไว้ยังตรวจได้หลัง Close
+func (b *Bus) Close(ctx context.Context) error {
+	err := b.inner.Close(ctx)
+	b.cancel()
+	b.wg.Wait()
+	return err
+}
+
+// Records คืนทุก Publish ที่บันทึกไว้ตามลำดับ (รวมที่ bus ปลายทางคืน error)
+func (b *Bus) Records() []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Record(nil), b.records...)
+}
+
+// Published คืน event ที่ publish สำเร็จบน topic (เป็น pattern ได้) ตามลำดับ
+func (b *Bus) Published(topic pubsub.Topic) []pubsub.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []pubsub.Event
+	for _, r := range b.records {
+		if r.Err == nil && pubsub.Match(topic, r.Event.Topic) {
+			out = append(out, r.Event)
+		}
+	}
+	return out
+}
+
+// Reset ล้างสิ่งที่บันทึกไว้ (handler ที่ลงทะเบียนยังอยู่)
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = nil
+}
+
+// WaitForEvent รอจนมี event บน topic (เป็น pattern ได้) ที่ publish สำเร็จ แล้วคืนตัวแรกที่พบ
+// นับรวม event ที่ publish ไปแล้วก่อนเรียกด้วย จึงไม่พลาดเมื่อ handler ทำงานเร็วกว่า test
+func (b *Bus) WaitForEvent(ctx context.Context, topic pubsub.Topic, matchers ...Matcher) (pubsub.Event, error) {
+	for {
+		b.mu.Lock()
+		for _, r := range b.records {
+			if r.Err == nil && pubsub.Match(topic, r.Event.Topic) && matchAll(r.Event, matchers) {
+				b.mu.Unlock()
+				return r.Event, nil
+			}
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return pubsub.Event{}, ctx.Err()
+		}
+	}
+}
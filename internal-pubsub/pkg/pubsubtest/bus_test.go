@@ -0,0 +1,102 @@
+package pubsubtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"internal-pubsub/examples/subscriber/handler"
+	"internal-pubsub/examples/subscriber/model"
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/pubsubtest"
+	"internal-pubsub/pkg/subscriber"
+)
+
+func TestSyncBusRunsHandlerChainInsidePublish(t *testing.T) {
+	bus := pubsubtest.NewSync()
+	defer bus.Close(context.Background())
+
+	inv := handler.NewInvoiceHandler(bus, "invoice.generated")
+	bus.Handle("order.created", subscriber.Typed(inv.CreateInvoice))
+
+	var mailed []model.Invoice
+	bus.Handle("invoice.*", subscriber.Typed(func(ctx context.Context, v model.Invoice) error {
+		mailed = append(mailed, v)
+		return nil
+	}))
+
+	if err := bus.Publish(context.Background(), "order.created", model.Order{ID: "ORD-1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	order := bus.AssertPublished(t, "order.created")
+	bus.AssertPublished(t, "invoice.generated",
+		pubsubtest.Data(func(v model.Invoice) bool { return v.OrderID == "ORD-1" }),
+		pubsubtest.CausedBy(order),
+	)
+	if len(mailed) != 1 || mailed[0].OrderID != "ORD-1" {
+		t.Fatalf("mailed = %+v, want one invoice for ORD-1", mailed)
+	}
+	bus.AssertNotPublished(t, "order.canceled")
+}
+
+func TestSyncBusReturnsHandlerError(t *testing.T) {
+	bus := pubsubtest.NewSync()
+	defer bus.Close(context.Background())
+
+	boom := errors.New("boom")
+	bus.Handle("order.created", func(ctx context.Context, ev pubsub.Event) error { return boom })
+
+	if err := bus.Publish(context.Background(), "order.created", "ORD-1"); !errors.Is(err, boom) {
+		t.Fatalf("publish err = %v, want boom", err)
+	}
+	// event ถูก publish สำเร็จแม้ handler จะล้มเหลว
+	bus.AssertPublished(t, "order.created", pubsubtest.DataEqual("ORD-1"))
+}
+
+func TestWaitForEventWithAsyncHandler(t *testing.T) {
+	bus := pubsubtest.New(pubsubtest.Options{})
+	defer bus.Close(context.Background())
+
+	inv := handler.NewInvoiceHandler(bus, "invoice.generated")
+	bus.Handle("order.created", subscriber.Typed(inv.CreateInvoice),
+		subscriber.WithLogger(log.New(io.Discard, "", 0)))
+
+	if err := bus.Publish(context.Background(), "order.created", model.Order{ID: "ORD-2"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ev, err := bus.WaitForEvent(ctx, "invoice.generated")
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if v, _ := pubsub.DataAs[model.Invoice](ev); v.OrderID != "ORD-2" {
+		t.Fatalf("invoice = %+v, want order ORD-2", v)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := bus.WaitForEvent(ctx, "order.canceled"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestRecordsPublishAfterClose(t *testing.T) {
+	bus := pubsubtest.NewSync()
+	_ = bus.Close(context.Background())
+
+	if err := bus.Publish(context.Background(), "order.created", "late"); !errors.Is(err, pubsub.ErrClosed) {
+		t.Fatalf("publish err = %v, want ErrClosed", err)
+	}
+	records := bus.Records()
+	if len(records) != 1 || !errors.Is(records[0].Err, pubsub.ErrClosed) {
+		t.Fatalf("records = %+v", records)
+	}
+	if got := bus.Published("order.created"); len(got) != 0 {
+		t.Fatalf("published = %+v, want none", got)
+	}
+}
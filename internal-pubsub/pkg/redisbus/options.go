@@ -0,0 +1,51 @@
+package redisbus
+
+import (
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Options ปรับแต่งพฤติกรรมของ redis bus
+type Options struct {
+	// StreamPrefix นำหน้าชื่อ stream ของแต่ละ topic เช่น "pubsub:" + "order.created"
+	StreamPrefix string
+	// Group ถ้ากำหนด ทุก subscription (ทุก process) ที่ใช้ชื่อเดียวกันจะแบ่งกันรับ event (competing consumers)
+	// ถ้าว่าง แต่ละ subscription จะได้ group ชั่วคราวของตัวเอง จึงได้ทุก event แบบ broadcast
+	Group string
+	// Consumer ชื่อ consumer ภายใน group (ว่าง = สุ่มต่อ subscription)
+	// ถ้ากำหนดชื่อคงที่ subscription ใหม่จะได้ message ที่ consumer ชื่อนี้รับไปแล้วแต่ยังไม่ ack ก่อน
+	Consumer string
+	// ClaimIdle message ใน pending ของ group ที่ค้างนานกว่านี้ (เช่น consumer เดิม crash) จะถูก XAUTOCLAIM
+	// มาส่งให้ subscription นี้ ตรวจทุก ClaimIdle (0 = ค่าเริ่มต้น, < 0 = ไม่ reclaim)
+	// ไม่ใช้กับ group ชั่วคราวที่สร้างให้ subscription เดียว
+	ClaimIdle time.Duration
+	// MaxLen จำกัดความยาว stream แบบประมาณ (XADD MAXLEN ~) 0 = ไม่จำกัด
+	MaxLen int64
+	// BlockTimeout ระยะรอของ XREADGROUP แต่ละรอบ (กำหนดว่า Unsubscribe จะหยุดได้เร็วแค่ไหน)
+	BlockTimeout time.Duration
+	// BatchSize จำนวน message สูงสุดต่อการอ่านหนึ่งครั้ง
+	BatchSize int64
+	// DefaultBuffer ความจุ chan เริ่มต้นของ subscriber (ถ้าไม่ระบุ)
+	DefaultBuffer int
+	// Codec แปลง Event.Data ก่อนเขียนลง stream (nil = pubsub.DefaultRegistry)
+	Codec pubsub.PayloadCodec
+	// RetryBackoff ระยะรอก่อนอ่านใหม่เมื่อ XREADGROUP ล้มเหลว (เช่น network หลุด, failover)
+	// เพิ่มเท่าตัวทุกครั้งที่ล้มเหลวติดกันจนถึง MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// DefaultOptions ค่าปริยาย
+func DefaultOptions() Options {
+	return Options{
+		StreamPrefix:  "pubsub:",
+		BlockTimeout:  time.Second,
+		BatchSize:     16,
+		DefaultBuffer: 1,
+
+		ClaimIdle:       time.Minute,
+		RetryBackoff:    100 * time.Millisecond,
+		MaxRetryBackoff: 5 * time.Second,
+	}
+}
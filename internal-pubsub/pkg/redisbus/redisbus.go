@@ -0,0 +1,218 @@
+// Package redisbus คือ pubsub.Bus บน Redis Streams (XADD/XREADGROUP/XACK)
+// ใช้ส่ง event ข้าม process/replica โดยโค้ดฝั่ง subscriber.Subscriber ไม่ต้องเปลี่ยน
+package redisbus
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+const eventField = "event"
+
+type redisBus struct {
+	rdb  redis.UniversalClient
+	opts Options
+
+	mu     sync.Mutex
+	subs   map[*redisSub]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New สร้าง Bus บน client ที่มีอยู่ (bus ไม่ปิด client ให้ ผู้เรียกเป็นเจ้าของ)
+// Data ของ event ถูก encode ด้วย Options.Codec (ค่าเริ่มต้น pubsub.DefaultRegistry ซึ่งใช้ gob)
+func New(rdb redis.UniversalClient, opts Options) pubsub.Bus {
+	def := DefaultOptions()
+	if opts.StreamPrefix == "" {
+		opts.StreamPrefix = def.StreamPrefix
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = def.BlockTimeout
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.DefaultBuffer <= 0 {
+		opts.DefaultBuffer = def.DefaultBuffer
+	}
+	if opts.ClaimIdle == 0 {
+		opts.ClaimIdle = def.ClaimIdle
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = def.RetryBackoff
+	}
+	if opts.MaxRetryBackoff < opts.RetryBackoff {
+		opts.MaxRetryBackoff = max(def.MaxRetryBackoff, opts.RetryBackoff)
+	}
+	return &redisBus{rdb: rdb, opts: opts, subs: make(map[*redisSub]struct{})}
+}
+
+func (b *redisBus) stream(topic pubsub.Topic) string {
+	return b.opts.StreamPrefix + string(topic)
+}
+
+// Subscribe สร้าง consumer group (ถ้ายังไม่มี) แล้วอ่าน event ใหม่จาก stream ของ topic
+// ก่อนอ่าน event ใหม่จะส่ง message ที่ consumer นี้รับไปแล้วแต่ยังไม่ ack (เช่นก่อน crash) ให้ก่อน
+// และ reclaim message ที่ค้างใน group นานกว่า Options.ClaimIdle เป็นระยะ (at-least-once)
+// ไม่รองรับ wildcard เพราะ stream ผูกกับ topic เดียว
+// pubsub.WithGroup ใช้แทน Options.Group ได้เฉพาะ subscription นั้น
+// ถ้าสมัครด้วย pubsub.WithDoneTracking (subscriber.New ทำให้เอง) จะ XACK message ของ event ที่ส่งให้ MarkDone
+// ไม่เช่นนั้นจะ XACK ทันทีที่ส่งเข้า chan
+func (b *redisBus) Subscribe(topic pubsub.Topic, buffer int, opts ...pubsub.SubscribeOption) pubsub.Subscription {
+	var so pubsub.SubscribeOptions
+	for _, f := range opts {
+		f(&so)
+	}
+	if buffer <= 0 {
+		buffer = b.opts.DefaultBuffer
+	}
+
+	s := &redisSub{
+		bus:       b,
+		topic:     topic,
+		stream:    b.stream(topic),
+		group:     b.opts.Group,
+		consumer:  b.opts.Consumer,
+		manualAck: so.TrackDone,
+		ch:        make(chan pubsub.Event, buffer),
+		pending:   make(map[string]struct{}),
+	}
+	if so.Group != "" {
+		s.group = so.Group
+	}
+	if s.group == "" {
+		s.group = "sub-" + newID()
+		s.ephemeral = true
+	}
+	if s.consumer == "" {
+		s.consumer = newID()
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if topic.IsPattern() {
+		log.Printf("[redisbus] wildcard topic %q is not supported", topic)
+		return s.stop()
+	}
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return s.stop()
+	}
+
+	// สร้าง group นอก lock เพื่อไม่ให้ redis ที่ช้าขวาง Publish/Subscribe/Close ตัวอื่น
+	if err := s.createGroup(); err != nil {
+		log.Printf("[redisbus] create group stream=%s group=%s err=%v", s.stream, s.group, err)
+		return s.stop()
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		s.cleanup()
+		return s.stop()
+	}
+	b.subs[s] = struct{}{}
+	b.wg.Add(1)
+	b.mu.Unlock()
+	go s.read()
+	return s
+}
+
+// Publish เพิ่ม event เข้า stream ของ topic
+func (b *redisBus) Publish(ctx context.Context, topic pubsub.Topic, data any) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return pubsub.ErrClosed
+	}
+
+	ev := pubsub.NewEvent(ctx, topic, data)
+	payload, err := encodeEvent(b.opts.Codec, ev)
+	if err != nil {
+		return err
+	}
+	args := &redis.XAddArgs{
+		Stream: b.stream(topic),
+		Values: map[string]any{eventField: payload},
+	}
+	if b.opts.MaxLen > 0 {
+		args.MaxLen = b.opts.MaxLen
+		args.Approx = true
+	}
+	return b.rdb.XAdd(ctx, args).Err()
+}
+
+// Close หยุดทุก subscription และลบ group ชั่วคราว (ไม่ปิด redis client)
+func (b *redisBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	subs := make([]*redisSub, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *redisBus) removeSub(s *redisSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
+
+func encodeEvent(c pubsub.PayloadCodec, ev pubsub.Event) ([]byte, error) {
+	enc, err := pubsub.EncodeEvent(c, ev)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&enc); err != nil {
+		return nil, fmt.Errorf("redisbus: encode topic=%s: %w", ev.Topic, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeEvent(c pubsub.PayloadCodec, raw string) (pubsub.Event, error) {
+	var enc pubsub.EncodedEvent
+	if err := gob.NewDecoder(strings.NewReader(raw)).Decode(&enc); err != nil {
+		return pubsub.Event{}, fmt.Errorf("redisbus: decode: %w", err)
+	}
+	return enc.Decode(c)
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,260 @@
+package redisbus
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/subscriber"
+)
+
+type order struct{ ID string }
+
+func init() { gob.Register(order{}) }
+
+func newTestBus(t *testing.T, opts Options) (pubsub.Bus, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	opts.BlockTimeout = 50 * time.Millisecond
+	return New(rdb, opts), rdb
+}
+
+func TestBroadcastToEverySubscription(t *testing.T) {
+	bus, _ := newTestBus(t, DefaultOptions())
+	defer bus.Close(context.Background())
+
+	a := bus.Subscribe("order.created", 4)
+	b := bus.Subscribe("order.created", 4)
+	if err := bus.Publish(context.Background(), "order.created", order{ID: "ORD-1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for _, sub := range []pubsub.Subscription{a, b} {
+		select {
+		case ev := <-sub.C():
+			if ev.Data.(order).ID != "ORD-1" || ev.Headers[HeaderMessageID] == "" {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestSubscriberRunsUnchanged(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Group = "invoicing"
+	bus, rdb := newTestBus(t, opts)
+	defer bus.Close(context.Background())
+
+	sub := subscriber.New(bus, "order.created", 4, subscriber.WithLogger(log.New(io.Discard, "", 0)))
+	got := make(chan string, 1)
+	go sub.Run(context.Background(), subscriber.Typed(func(ctx context.Context, o order) error {
+		got <- o.ID
+		return nil
+	}))
+
+	_ = bus.Publish(context.Background(), "order.created", order{ID: "ORD-2"})
+	select {
+	case id := <-got:
+		if id != "ORD-2" {
+			t.Fatalf("got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for handler")
+	}
+
+	// MarkDone หลัง handler ต้อง XACK ทำให้ไม่เหลือ pending
+	deadline := time.Now().Add(time.Second)
+	for {
+		pending, err := rdb.XPending(context.Background(), "pubsub:order.created", "invoicing").Result()
+		if err == nil && pending.Count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message still pending: %+v err=%v", pending, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// emptyCodec แทน payload ทุกตัวด้วย byte ว่าง
+type emptyCodec struct{}
+
+func (emptyCodec) Encode(pubsub.Topic, any) ([]byte, error) { return nil, nil }
+func (emptyCodec) Decode(pubsub.Topic, []byte) (any, error) { return nil, nil }
+
+func TestDecodeEventWithEmptyPayload(t *testing.T) {
+	ev := pubsub.NewEvent(context.Background(), "order.ping", nil)
+	raw, err := encodeEvent(emptyCodec{}, ev)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := decodeEvent(emptyCodec{}, string(raw))
+	if err != nil || got.ID != ev.ID || got.Topic != ev.Topic {
+		t.Fatalf("decode = %+v, %v", got, err)
+	}
+}
+
+func TestReadRetriesAfterRedisError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	opts := DefaultOptions()
+	opts.BlockTimeout = 20 * time.Millisecond
+	opts.RetryBackoff = 5 * time.Millisecond
+	bus := New(rdb, opts)
+	defer bus.Close(context.Background())
+
+	sub := bus.Subscribe("order.created", 4)
+	mr.SetError("LOADING redis is loading the dataset in memory")
+	time.Sleep(100 * time.Millisecond)
+	mr.SetError("")
+
+	if err := bus.Publish(context.Background(), "order.created", order{ID: "ORD-3"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case ev, ok := <-sub.C():
+		if !ok {
+			t.Fatal("subscription closed after a transient redis error")
+		}
+		if ev.Data.(order).ID != "ORD-3" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event after redis recovered")
+	}
+}
+
+func nextOrder(t *testing.T, sub pubsub.Subscription) pubsub.Event {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.C():
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return pubsub.Event{}
+	}
+}
+
+func TestUnackedMessagesAreRedelivered(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	newBus := func(consumer string, claimIdle time.Duration) pubsub.Bus {
+		opts := DefaultOptions()
+		opts.Group, opts.Consumer, opts.ClaimIdle = "invoicing", consumer, claimIdle
+		opts.BlockTimeout = 20 * time.Millisecond
+		return New(rdb, opts)
+	}
+
+	// worker-1 รับ ORD-1 แล้ว "crash" ก่อน MarkDone
+	crashed := newBus("worker-1", -1)
+	sub := crashed.Subscribe("order.created", 4, pubsub.WithDoneTracking())
+	_ = crashed.Publish(context.Background(), "order.created", order{ID: "ORD-1"})
+	if ev := nextOrder(t, sub); ev.Data.(order).ID != "ORD-1" {
+		t.Fatalf("got %v", ev.Data)
+	}
+	_ = crashed.Close(context.Background())
+
+	// consumer ชื่อเดิมกลับมา: ได้ backlog ของตัวเองก่อน
+	restarted := newBus("worker-1", -1)
+	sub = restarted.Subscribe("order.created", 4, pubsub.WithDoneTracking())
+	if ev := nextOrder(t, sub); ev.Data.(order).ID != "ORD-1" {
+		t.Fatalf("backlog = %v, want ORD-1", ev.Data)
+	}
+	_ = restarted.Close(context.Background())
+
+	// consumer อื่น reclaim message ที่ค้างนานเกิน ClaimIdle
+	other := newBus("worker-2", 30*time.Millisecond)
+	defer other.Close(context.Background())
+	sub = other.Subscribe("order.created", 4, pubsub.WithDoneTracking())
+	ev := nextOrder(t, sub)
+	if ev.Data.(order).ID != "ORD-1" {
+		t.Fatalf("claimed = %v, want ORD-1", ev.Data)
+	}
+	sub.(pubsub.DoneMarker).MarkDone(ev)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		pending, err := rdb.XPending(context.Background(), "pubsub:order.created", "invoicing").Result()
+		if err == nil && pending.Count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message still pending: %+v err=%v", pending, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConcurrentMarkDoneAcksFinishedMessage(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Group = "invoicing"
+	bus, rdb := newTestBus(t, opts)
+	defer bus.Close(context.Background())
+
+	sub := subscriber.New(bus, "order.created", 4, subscriber.WithLogger(log.New(io.Discard, "", 0)))
+	release := make(chan struct{})
+	handled := make(chan string, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.RunConcurrent(ctx, 2, subscriber.Typed(func(ctx context.Context, o order) error {
+		if o.ID == "ORD-1" {
+			<-release // ORD-1 ช้า ORD-2 จึงเสร็จก่อน
+		}
+		handled <- o.ID
+		return nil
+	}))
+
+	_ = bus.Publish(context.Background(), "order.created", order{ID: "ORD-1"})
+	_ = bus.Publish(context.Background(), "order.created", order{ID: "ORD-2"})
+	if id := <-handled; id != "ORD-2" {
+		t.Fatalf("first handled = %s, want ORD-2", id)
+	}
+	msgs, err := rdb.XRange(context.Background(), "pubsub:order.created", "-", "+").Result()
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("xrange = %v, %v", msgs, err)
+	}
+
+	pendingIDs := func(want int) []string {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			ps, err := rdb.XPendingExt(context.Background(), &redis.XPendingExtArgs{
+				Stream: "pubsub:order.created", Group: "invoicing", Start: "-", End: "+", Count: 10,
+			}).Result()
+			if err == nil && len(ps) == want {
+				ids := make([]string, len(ps))
+				for i, p := range ps {
+					ids[i] = p.ID
+				}
+				return ids
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("pending = %+v err=%v, want %d entries", ps, err, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	// ORD-2 เสร็จก่อนต้อง ack ORD-2 เท่านั้น ORD-1 ที่ยังทำอยู่ต้องค้างใน pending
+	if ids := pendingIDs(1); ids[0] != msgs[0].ID {
+		t.Fatalf("pending = %v, want only ORD-1 (%s)", ids, msgs[0].ID)
+	}
+	close(release)
+	<-handled
+	pendingIDs(0)
+}
@@ -0,0 +1,272 @@
+package redisbus
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// HeaderMessageID header ที่บอก id ของ message ใน stream
+const HeaderMessageID = "redis-message-id"
+
+type redisSub struct {
+	bus       *redisBus
+	topic     pubsub.Topic
+	stream    string
+	group     string
+	consumer  string
+	ephemeral bool // group สร้างเฉพาะ subscription นี้ ลบทิ้งตอน Unsubscribe
+	manualAck bool // XACK ตอน MarkDone แทนตอนส่งเข้า chan
+	ch        chan pubsub.Event
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	mu      sync.Mutex
+	pending map[string]struct{} // message id ที่ส่งออกไปแล้วแต่ยังไม่ ack (เฉพาะ manualAck)
+}
+
+func (s *redisSub) C() <-chan pubsub.Event { return s.ch }
+
+func (s *redisSub) Unsubscribe() {
+	s.once.Do(s.cancel)
+}
+
+// MarkDone XACK message ของ ev (ระบุด้วย HeaderMessageID) เรียกลำดับใดก็ได้
+// ev ที่ไม่ได้มาจาก subscription นี้หรือ ack ไปแล้วจะถูกเพิกเฉย
+func (s *redisSub) MarkDone(ev pubsub.Event) {
+	id := ev.Headers[HeaderMessageID]
+	s.mu.Lock()
+	_, ok := s.pending[id]
+	delete(s.pending, id)
+	s.mu.Unlock()
+	if ok {
+		s.ack(id)
+	}
+}
+
+func (s *redisSub) ack(id string) {
+	// ใช้ Background เพราะอาจถูกเรียกหลัง Unsubscribe แล้ว
+	if err := s.bus.rdb.XAck(context.Background(), s.stream, s.group, id).Err(); err != nil {
+		log.Printf("[redisbus] ack stream=%s id=%s err=%v", s.stream, id, err)
+	}
+}
+
+// read วน XREADGROUP จนกว่าจะ Unsubscribe แล้วปิด chan
+// error จาก redis (เช่น network หลุดหรือ failover) ไม่ทำให้ subscription จบ แต่จะอ่านใหม่ด้วย backoff
+func (s *redisSub) read() {
+	defer s.bus.wg.Done()
+	defer s.bus.removeSub(s)
+	defer close(s.ch)
+	defer s.cleanup()
+
+	if !s.ephemeral && !s.readBacklog() {
+		return
+	}
+	var (
+		backoff   time.Duration
+		nextClaim = time.Now().Add(s.bus.opts.ClaimIdle)
+	)
+	for s.ctx.Err() == nil {
+		if s.claims() && !time.Now().Before(nextClaim) {
+			if !s.claim() {
+				return
+			}
+			nextClaim = time.Now().Add(s.bus.opts.ClaimIdle)
+		}
+		res, err := s.bus.rdb.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    s.bus.opts.BatchSize,
+			Block:    s.bus.opts.BlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || s.ctx.Err() != nil {
+				continue
+			}
+			backoff = s.nextBackoff(backoff)
+			log.Printf("[redisbus] read stream=%s group=%s retry=%s err=%v", s.stream, s.group, backoff, err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// group หายไป (เช่น redis เสียข้อมูลหลัง failover) สร้างใหม่ก่อนอ่านรอบถัดไป
+				if cerr := s.createGroup(); cerr != nil {
+					log.Printf("[redisbus] create group stream=%s group=%s err=%v", s.stream, s.group, cerr)
+				}
+			}
+			if !s.sleep(backoff) {
+				return
+			}
+			continue
+		}
+		backoff = 0
+		for _, st := range res {
+			for _, msg := range st.Messages {
+				if !s.deliver(msg) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// readBacklog ส่ง message ที่ consumer นี้รับไปแล้วแต่ยังไม่ ack (อ่านด้วย id "0")
+// คืน false ถ้าถูก Unsubscribe ระหว่างส่ง
+func (s *redisSub) readBacklog() bool {
+	start := "0"
+	for s.ctx.Err() == nil {
+		res, err := s.bus.rdb.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, start},
+			Count:    s.bus.opts.BatchSize,
+			Block:    -1, // ไม่ BLOCK
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && s.ctx.Err() == nil {
+				// ที่เหลือจะถูก reclaim ภายหลังด้วย claim
+				log.Printf("[redisbus] read backlog stream=%s group=%s err=%v", s.stream, s.group, err)
+			}
+			return s.ctx.Err() == nil
+		}
+		if len(res) == 0 || len(res[0].Messages) == 0 {
+			return true
+		}
+		for _, msg := range res[0].Messages {
+			if !s.deliver(msg) {
+				return false
+			}
+			start = msg.ID
+		}
+	}
+	return false
+}
+
+func (s *redisSub) claims() bool { return !s.ephemeral && s.bus.opts.ClaimIdle > 0 }
+
+// claim ย้าย message ที่ค้างใน pending ของ group นานกว่า ClaimIdle มาเป็นของ consumer นี้แล้วส่งต่อ
+// คืน false ถ้าถูก Unsubscribe ระหว่างส่ง
+func (s *redisSub) claim() bool {
+	start := "0-0"
+	for s.ctx.Err() == nil {
+		msgs, next, err := s.bus.rdb.XAutoClaim(s.ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  s.bus.opts.ClaimIdle,
+			Start:    start,
+			Count:    s.bus.opts.BatchSize,
+		}).Result()
+		if err != nil {
+			if s.ctx.Err() == nil {
+				log.Printf("[redisbus] claim stream=%s group=%s err=%v", s.stream, s.group, err)
+			}
+			return s.ctx.Err() == nil
+		}
+		for _, msg := range msgs {
+			if s.inFlight(msg.ID) {
+				continue // consumer นี้ยังประมวลผลอยู่ (handler ช้า)
+			}
+			if !s.deliver(msg) {
+				return false
+			}
+		}
+		if next == "" || next == "0-0" {
+			return true
+		}
+		start = next
+	}
+	return false
+}
+
+// inFlight บอกว่า message id นี้ถูกส่งออกไปแล้วและยังรอ MarkDone
+func (s *redisSub) inFlight(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pending[id]
+	return ok
+}
+
+// createGroup สร้าง consumer group ถ้ายังไม่มี
+// "$" = เริ่มจาก message ใหม่หลังจากนี้, MKSTREAM สร้าง stream ถ้ายังไม่มี
+func (s *redisSub) createGroup() error {
+	err := s.bus.rdb.XGroupCreateMkStream(s.ctx, s.stream, s.group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (s *redisSub) nextBackoff(prev time.Duration) time.Duration {
+	if prev <= 0 {
+		return s.bus.opts.RetryBackoff
+	}
+	return min(prev*2, s.bus.opts.MaxRetryBackoff)
+}
+
+// sleep รอ d คืน false ถ้าถูก Unsubscribe ระหว่างรอ
+func (s *redisSub) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// deliver คืน false เมื่อถูก Unsubscribe ระหว่างรอส่ง
+func (s *redisSub) deliver(msg redis.XMessage) bool {
+	raw, _ := msg.Values[eventField].(string)
+	ev, err := decodeEvent(s.bus.opts.Codec, raw)
+	if err != nil {
+		// message เสีย: ack ทิ้งเพื่อไม่ให้ค้างใน pending ตลอดไป
+		log.Printf("[redisbus] stream=%s id=%s err=%v", s.stream, msg.ID, err)
+		s.ack(msg.ID)
+		return true
+	}
+	if ev.Headers == nil {
+		ev.Headers = make(map[string]string, 1)
+	}
+	ev.Headers[HeaderMessageID] = msg.ID
+
+	if s.manualAck {
+		s.mu.Lock()
+		s.pending[msg.ID] = struct{}{}
+		s.mu.Unlock()
+	}
+	select {
+	case s.ch <- ev:
+		if !s.manualAck {
+			s.ack(msg.ID)
+		}
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// stop ปิด subscription ที่ไม่ได้เริ่มอ่าน แล้วคืนตัวเอง
+func (s *redisSub) stop() *redisSub {
+	close(s.ch)
+	s.cancel()
+	return s
+}
+
+// cleanup ลบ group ชั่วคราวเพื่อไม่ให้ค้างใน redis
+func (s *redisSub) cleanup() {
+	if !s.ephemeral {
+		return
+	}
+	if err := s.bus.rdb.XGroupDestroy(context.Background(), s.stream, s.group).Err(); err != nil {
+		log.Printf("[redisbus] destroy group stream=%s group=%s err=%v", s.stream, s.group, err)
+	}
+}
@@ -0,0 +1,406 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/subscriber"
+)
+
+// ErrStarted คืนจาก Register เมื่อ Orchestrator เริ่มทำงานแล้ว
+var ErrStarted = errors.New("saga: orchestrator already started")
+
+// DefaultDeadLetterTopic topic ที่ Orchestrator ใช้รับ event ที่ step ล้มเหลวจน retry ครบ
+const DefaultDeadLetterTopic pubsub.Topic = "saga.dead-letter"
+
+// Options ปรับพฤติกรรมของ Orchestrator
+type Options struct {
+	Clock      pubsub.Clock        // แหล่งเวลาของ Timeout (nil = pubsub.SystemClock)
+	Logger     *log.Logger         // nil = log.Default()
+	Buffer     int                 // buffer ของแต่ละ subscription
+	Subscriber []subscriber.Option // option เพิ่มเติมของ subscriber (เช่น WithRetry, WithMiddleware)
+
+	// DeadLetter topic ที่ subscriber ส่ง event ไปเมื่อ Action ล้มเหลวครบตาม RetryPolicy
+	// Orchestrator subscribe topic นี้เองแล้วเริ่มชดเชย (ว่าง = DefaultDeadLetterTopic)
+	// ทับ WithDeadLetter ที่ใส่ไว้ใน Subscriber
+	DeadLetter pubsub.Topic
+}
+
+// DefaultOptions ค่าแนะนำ: เวลาจริง buffer 64
+func DefaultOptions() Options {
+	return Options{Clock: pubsub.SystemClock, Logger: log.Default(), Buffer: 64, DeadLetter: DefaultDeadLetterTopic}
+}
+
+// Orchestrator รับ event จาก bus แล้วขับ saga ที่ลงทะเบียนไว้ พร้อมเก็บสถานะลง Store
+// event ของ instance เดียวกันถูกประมวลผลทีละตัว (ถือ lock ระหว่าง Action/Compensate)
+// Action ที่ publish event ของ step ถัดไปจึงไม่ต้องกังวลว่า step นั้นจะเห็นสถานะเก่า
+type Orchestrator struct {
+	bus   pubsub.Bus
+	store Store
+	opts  Options
+	sup   *subscriber.Supervisor
+
+	mu       sync.Mutex
+	defs     []Definition
+	started  bool
+	timers   map[string]pubsub.Timer // key = State.ID
+	locks    map[string]*instanceLock
+	stopping bool
+}
+
+type instanceLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// binding คือ topic หนึ่งที่ saga หนึ่งสนใจ (step >= 0 คือ index ของ step, -1 คือ FailOn)
+type binding struct {
+	def  Definition
+	on   pubsub.Topic
+	step int
+}
+
+// New สร้าง Orchestrator ที่ยังไม่เริ่มทำงาน
+func New(bus pubsub.Bus, store Store, opts Options) *Orchestrator {
+	if opts.Clock == nil {
+		opts.Clock = pubsub.SystemClock
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	if opts.DeadLetter == "" {
+		opts.DeadLetter = DefaultDeadLetterTopic
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Orchestrator{
+		bus:    bus,
+		store:  store,
+		opts:   opts,
+		sup:    subscriber.NewSupervisor(bus, subscriber.WithSupervisorLogger(opts.Logger)),
+		timers: make(map[string]pubsub.Timer),
+		locks:  make(map[string]*instanceLock),
+	}
+}
+
+// Register เพิ่ม saga ต้องเรียกก่อน Start
+func (o *Orchestrator) Register(def Definition) error {
+	if err := def.validate(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started {
+		return ErrStarted
+	}
+	for _, d := range o.defs {
+		if d.Name == def.Name {
+			return fmt.Errorf("saga %s: already registered", def.Name)
+		}
+	}
+	o.defs = append(o.defs, def)
+	return nil
+}
+
+// Start subscribe ทุก topic ที่ saga ใช้ และตั้ง timeout ของ instance ที่ยังค้างอยู่ใน Store
+// (เช่นหลัง restart ด้วย Store ที่เก็บถาวร) ยกเลิก ctx เพื่อหยุด แล้วใช้ Wait รอให้จบ
+func (o *Orchestrator) Start(ctx context.Context) error {
+	o.mu.Lock()
+	if o.started {
+		o.mu.Unlock()
+		return ErrStarted
+	}
+	o.started = true
+	defs := o.defs
+	o.mu.Unlock()
+
+	running, err := o.store.List(ctx, Query{Status: StatusRunning})
+	if err != nil {
+		return fmt.Errorf("saga: restore: %w", err)
+	}
+	for _, st := range running {
+		if def, ok := o.definition(st.Saga); ok {
+			o.schedule(def, st)
+		}
+	}
+
+	byTopic := make(map[pubsub.Topic][]binding)
+	var (
+		topics []pubsub.Topic
+		all    []binding
+	)
+	add := func(b binding) {
+		if _, ok := byTopic[b.on]; !ok {
+			topics = append(topics, b.on)
+		}
+		byTopic[b.on] = append(byTopic[b.on], b)
+		all = append(all, b)
+	}
+	for _, def := range defs {
+		for i, s := range def.Steps {
+			add(binding{def: def, on: s.On, step: i})
+		}
+		for _, t := range def.FailOn {
+			add(binding{def: def, on: t, step: -1})
+		}
+	}
+	for _, t := range topics {
+		bs := byTopic[t]
+		o.sup.Add(t, o.opts.Buffer, func(ctx context.Context, ev pubsub.Event) error {
+			var errs []error
+			for _, b := range bs {
+				errs = append(errs, o.apply(ctx, b, ev))
+			}
+			return errors.Join(errs...)
+		}, append(slices.Clone(o.opts.Subscriber), subscriber.WithDeadLetter(o.opts.DeadLetter))...)
+	}
+	o.sup.Add(o.opts.DeadLetter, o.opts.Buffer, subscriber.Typed(func(ctx context.Context, dl subscriber.DeadLetter) error {
+		var errs []error
+		for _, b := range all {
+			if pubsub.Match(b.on, dl.Event.Topic) {
+				errs = append(errs, o.giveUp(ctx, b, dl))
+			}
+		}
+		return errors.Join(errs...)
+	}), o.opts.Subscriber...)
+	o.sup.Start(ctx)
+
+	go func() {
+		<-ctx.Done()
+		o.stopTimers()
+	}()
+	return nil
+}
+
+// Wait รอจน subscriber ทุกตัวจบ (ดู subscriber.Supervisor.Wait)
+func (o *Orchestrator) Wait() error { return o.sup.Wait() }
+
+// Get คืนสถานะปัจจุบันของ saga instance (ErrNotFound ถ้าไม่มี)
+func (o *Orchestrator) Get(ctx context.Context, id string) (State, error) {
+	return o.store.Get(ctx, id)
+}
+
+// List คืน saga instance ที่ตรงเงื่อนไข
+func (o *Orchestrator) List(ctx context.Context, q Query) ([]State, error) {
+	return o.store.List(ctx, q)
+}
+
+func (o *Orchestrator) definition(name string) (Definition, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, d := range o.defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// load อ่าน instance id ที่ binding นี้ต้องจัดการ (ต้องถือ lock ของ instance)
+// ok = false ถ้า event ไม่เกี่ยวกับ instance ที่กำลังทำงาน (ยังไม่เริ่ม หรือจบไปแล้ว)
+// ถ้ายังไม่มีและ binding เป็น step แรกจะคืน instance ใหม่ที่ยังไม่บันทึกพร้อม created = true
+func (o *Orchestrator) load(ctx context.Context, b binding, id string) (st State, created, ok bool, err error) {
+	st, err = o.store.Get(ctx, id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if b.step != 0 {
+			return st, false, false, nil
+		}
+		now := o.opts.Clock.Now()
+		st = State{ID: id, Saga: b.def.Name, Status: StatusRunning, Data: map[string]string{}, StartedAt: now}
+		if b.def.Timeout > 0 {
+			st.Deadline = now.Add(b.def.Timeout)
+		}
+		created = true
+	case err != nil:
+		return st, false, false, fmt.Errorf("saga %s: load %s: %w", b.def.Name, id, err)
+	}
+	if st.Saga != b.def.Name || st.Status != StatusRunning {
+		return st, false, false, nil
+	}
+	if st.Data == nil {
+		st.Data = map[string]string{}
+	}
+	return st, created, true, nil
+}
+
+// apply ประมวลผล event หนึ่งตัวกับ binding หนึ่งตัว
+// event ที่ไม่เกี่ยวกับ instance ที่กำลังทำงาน (ยังไม่เริ่ม จบไปแล้ว หรือ step ซ้ำ) ถูกเพิกเฉย
+// Action ที่ล้มเหลวคืน error ให้ subscriber retry ตาม RetryPolicy โดยไม่บันทึกสถานะ
+// ครบแล้วยังล้มเหลว event จะไปที่ Options.DeadLetter แล้ว giveUp จึงชดเชย
+func (o *Orchestrator) apply(ctx context.Context, b binding, ev pubsub.Event) error {
+	id := ev.CorrelationID()
+	unlock := o.lock(id)
+	defer unlock()
+
+	st, created, ok, err := o.load(ctx, b, id)
+	if !ok {
+		return err
+	}
+
+	if b.step < 0 {
+		o.opts.Logger.Printf("[saga] failed saga=%s id=%s event=%s", st.Saga, id, ev.Topic)
+		return o.compensate(ctx, b.def, &st, fmt.Sprintf("event %s", ev.Topic))
+	}
+	step := b.def.Steps[b.step]
+	if st.Done(step.Name) {
+		return nil
+	}
+	if step.Action != nil {
+		if err := step.Action(ctx, &st, ev); err != nil {
+			o.opts.Logger.Printf("[saga] step failed saga=%s id=%s step=%s err=%v", st.Saga, id, step.Name, err)
+			return fmt.Errorf("saga %s: step %s: %w", st.Saga, step.Name, err)
+		}
+	}
+	st.Completed = append(st.Completed, step.Name)
+	if len(st.Completed) == len(b.def.Steps) {
+		st.Status = StatusCompleted
+		o.cancelTimer(id)
+	}
+	if err := o.save(ctx, &st); err != nil {
+		return err
+	}
+	if created && st.Status == StatusRunning {
+		o.schedule(b.def, st)
+	}
+	return nil
+}
+
+// giveUp ชดเชย instance ของ event ที่ subscriber ยอมแพ้แล้ว (ได้รับจาก Options.DeadLetter)
+// step ที่สำเร็จไปแล้วระหว่างนั้น (เช่น retry ครั้งหลังผ่าน) ไม่ถูกชดเชย
+func (o *Orchestrator) giveUp(ctx context.Context, b binding, dl subscriber.DeadLetter) error {
+	id := dl.Event.CorrelationID()
+	unlock := o.lock(id)
+	defer unlock()
+
+	st, _, ok, err := o.load(ctx, b, id)
+	if !ok {
+		return err
+	}
+	reason := fmt.Sprintf("event %s", dl.Event.Topic)
+	if b.step >= 0 {
+		step := b.def.Steps[b.step]
+		if st.Done(step.Name) {
+			return nil
+		}
+		reason = dl.Error
+	}
+	o.opts.Logger.Printf("[saga] giving up saga=%s id=%s attempts=%d err=%s", st.Saga, id, dl.Attempts, dl.Error)
+	return o.compensate(ctx, b.def, &st, reason)
+}
+
+// compensate ชดเชย step ที่สำเร็จแล้วย้อนลำดับ แล้วบันทึกผล (ต้องถือ lock ของ instance)
+// หยุดที่งานชดเชยตัวแรกที่ล้มเหลวและบันทึกเป็น StatusCompensationFailed
+func (o *Orchestrator) compensate(ctx context.Context, def Definition, st *State, reason string) error {
+	o.cancelTimer(st.ID)
+	st.Reason = reason
+	st.Status = StatusCompensated
+	if _, ok := pubsub.EventFromContext(ctx); !ok {
+		ctx = pubsub.WithCorrelationID(ctx, st.ID)
+	}
+	for i := len(st.Completed) - 1; i >= 0; i-- {
+		name := st.Completed[i]
+		if slices.Contains(st.Compensated, name) {
+			continue
+		}
+		if s, ok := def.step(name); ok && s.Compensate != nil {
+			if err := s.Compensate(ctx, st); err != nil {
+				o.opts.Logger.Printf("[saga] compensation failed saga=%s id=%s step=%s err=%v", st.Saga, st.ID, name, err)
+				st.Status = StatusCompensationFailed
+				st.Reason = fmt.Sprintf("%s; compensate %s: %v", reason, name, err)
+				break
+			}
+		}
+		st.Compensated = append(st.Compensated, name)
+	}
+	return o.save(ctx, st)
+}
+
+func (o *Orchestrator) save(ctx context.Context, st *State) error {
+	st.UpdatedAt = o.opts.Clock.Now()
+	if err := o.store.Put(ctx, *st); err != nil {
+		return fmt.Errorf("saga %s: save %s: %w", st.Saga, st.ID, err)
+	}
+	return nil
+}
+
+// schedule ตั้ง timeout ของ instance ที่ยังทำงานอยู่ (ไม่มี Deadline = ไม่ตั้ง)
+func (o *Orchestrator) schedule(def Definition, st State) {
+	if st.Deadline.IsZero() {
+		return
+	}
+	d := max(st.Deadline.Sub(o.opts.Clock.Now()), 0)
+	id := st.ID
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopping {
+		return
+	}
+	if t := o.timers[id]; t != nil {
+		t.Stop()
+	}
+	o.timers[id] = o.opts.Clock.AfterFunc(d, func() { o.expire(def, id) })
+}
+
+func (o *Orchestrator) cancelTimer(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if t := o.timers[id]; t != nil {
+		t.Stop()
+		delete(o.timers, id)
+	}
+}
+
+func (o *Orchestrator) stopTimers() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stopping = true
+	for id, t := range o.timers {
+		t.Stop()
+		delete(o.timers, id)
+	}
+}
+
+// expire ถูกเรียกเมื่อ instance เกิน Timeout
+func (o *Orchestrator) expire(def Definition, id string) {
+	unlock := o.lock(id)
+	defer unlock()
+
+	ctx := context.Background()
+	st, err := o.store.Get(ctx, id)
+	if err != nil || st.Status != StatusRunning {
+		return
+	}
+	o.opts.Logger.Printf("[saga] timeout saga=%s id=%s completed=%v", st.Saga, id, st.Completed)
+	if err := o.compensate(ctx, def, &st, "timeout"); err != nil {
+		o.opts.Logger.Printf("[saga] %v", err)
+	}
+}
+
+// lock ล็อก instance id แล้วคืนฟังก์ชันปลดล็อก (entry ถูกลบเมื่อไม่มีใครใช้)
+func (o *Orchestrator) lock(id string) func() {
+	o.mu.Lock()
+	l := o.locks[id]
+	if l == nil {
+		l = &instanceLock{}
+		o.locks[id] = l
+	}
+	l.refs++
+	o.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		o.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(o.locks, id)
+		}
+		o.mu.Unlock()
+	}
+}
@@ -0,0 +1,129 @@
+// Package saga ประสานงานหลายขั้น (saga / process manager) บน pubsub.Bus
+// แต่ละ saga ประกาศ step ที่ทำงานเมื่อได้รับ event ของ topic หนึ่ง พร้อมงานชดเชย (compensation)
+// saga หนึ่งรอบ (instance) ผูกกับ correlation id ของ event ที่เริ่มมัน ซึ่ง bus สืบทอดให้ทุก event
+// ที่ publish ต่อจาก handler เอง event ต่อๆ มาจึงกลับมาหา instance เดิมได้โดยไม่ต้องส่ง id เพิ่ม
+// ถ้า step ล้มเหลว (หลัง retry ตาม Options.Subscriber ครบแล้ว) ได้รับ event ใน FailOn หรือเกิน Timeout
+// step ที่สำเร็จไปแล้วจะถูกชดเชยย้อนลำดับ
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Action คืองานของ step เรียกพร้อม event ที่ trigger (ctx ผูก event ไว้แล้ว Publish ต่อจึงคง correlation id)
+// แก้ st.Data ได้เพื่อเก็บข้อมูลไว้ใช้ใน step ถัดไปหรือตอนชดเชย
+// คืน error = ลองใหม่ตาม RetryPolicy ของ subscriber (การแก้ st ครั้งนั้นถูกทิ้ง) ครบแล้วจึงเริ่มชดเชย
+// Action จึงควร idempotent
+type Action func(ctx context.Context, st *State, ev pubsub.Event) error
+
+// Compensation ย้อนผลของ step ที่สำเร็จไปแล้ว
+type Compensation func(ctx context.Context, st *State) error
+
+// Step คือขั้นหนึ่งของ saga
+type Step struct {
+	Name       string
+	On         pubsub.Topic // event ที่ทำให้ step นี้ทำงาน (เป็น pattern ได้) step แรกคือ event ที่เริ่ม saga
+	Action     Action       // nil = แค่บันทึกว่าได้รับ event แล้ว
+	Compensate Compensation // nil = ไม่มีอะไรต้องย้อน
+}
+
+// Definition ประกาศ saga หนึ่งชนิด
+// saga เสร็จเมื่อทุก step ทำงานสำเร็จ แต่ละ step ทำงานครั้งเดียวต่อ instance (event ซ้ำถูกเพิกเฉย)
+type Definition struct {
+	Name    string
+	Steps   []Step
+	FailOn  []pubsub.Topic // event ที่บอกว่า saga ล้มเหลว (เช่น "mail.failed") ทำให้เริ่มชดเชย
+	Timeout time.Duration  // เวลาสูงสุดนับจากเริ่มจนเสร็จ (0 = ไม่จำกัด)
+}
+
+func (d Definition) validate() error {
+	if d.Name == "" {
+		return errors.New("saga: definition needs a name")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("saga %s: no steps", d.Name)
+	}
+	names := make(map[string]bool, len(d.Steps))
+	topics := make(map[pubsub.Topic]bool, len(d.Steps))
+	for _, s := range d.Steps {
+		switch {
+		case s.Name == "" || s.On == "":
+			return fmt.Errorf("saga %s: every step needs Name and On", d.Name)
+		case names[s.Name]:
+			return fmt.Errorf("saga %s: duplicate step %s", d.Name, s.Name)
+		case topics[s.On]:
+			return fmt.Errorf("saga %s: topic %s triggers more than one step", d.Name, s.On)
+		}
+		names[s.Name], topics[s.On] = true, true
+	}
+	return nil
+}
+
+func (d Definition) step(name string) (Step, bool) {
+	for _, s := range d.Steps {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Step{}, false
+}
+
+// Status สถานะของ saga instance
+type Status int
+
+const (
+	StatusRunning            Status = iota + 1 // กำลังรอ event ของ step ที่เหลือ
+	StatusCompleted                            // ทุก step สำเร็จ
+	StatusCompensated                          // ล้มเหลวและชดเชยครบแล้ว (ดู State.Reason)
+	StatusCompensationFailed                   // งานชดเชยล้มเหลว ต้องแก้ไขด้วยมือ
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusRunning:
+		return "running"
+	case StatusCompleted:
+		return "completed"
+	case StatusCompensated:
+		return "compensated"
+	case StatusCompensationFailed:
+		return "compensation_failed"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// State คือสถานะของ saga instance หนึ่งตัวตามที่เก็บใน Store
+type State struct {
+	ID          string // correlation id ของ event ที่เริ่ม saga
+	Saga        string // Definition.Name
+	Status      Status
+	Completed   []string          // step ที่สำเร็จแล้วตามลำดับ
+	Compensated []string          // step ที่ชดเชยแล้วตามลำดับที่ชดเชย
+	Data        map[string]string // ข้อมูลที่ step เก็บไว้ใช้ต่อ
+	Reason      string            // สาเหตุที่ล้มเหลว (เช่น "timeout")
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	Deadline    time.Time // zero = ไม่มี timeout
+}
+
+// Done บอกว่า step ชื่อ name สำเร็จแล้ว
+func (s State) Done(name string) bool { return slices.Contains(s.Completed, name) }
+
+func (s State) clone() State {
+	s.Completed = slices.Clone(s.Completed)
+	s.Compensated = slices.Clone(s.Compensated)
+	if s.Data != nil {
+		data := make(map[string]string, len(s.Data))
+		for k, v := range s.Data {
+			data[k] = v
+		}
+		s.Data = data
+	}
+	return s
+}
@@ -0,0 +1,354 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+	"internal-pubsub/pkg/subscriber"
+)
+
+// fakeClock เดินเวลาเมื่อเรียก Advance เท่านั้น และเรียก timer ที่ถึงเวลาใน goroutine ของผู้เรียก
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) pubsub.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	keep := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.at.After(c.now) {
+			due = append(due, t)
+		} else {
+			keep = append(keep, t)
+		}
+	}
+	c.timers = keep
+	c.mu.Unlock()
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// calls บันทึกลำดับงานที่ saga เรียก
+type calls struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (c *calls) add(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.log = append(c.log, s)
+}
+
+func (c *calls) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.log)
+}
+
+// orderSaga: order.created -> ออก invoice (publish invoice.generated) -> ส่งเมล
+func orderSaga(bus pubsub.Bus, c *calls, mailErr error) Definition {
+	return Definition{
+		Name: "order",
+		Steps: []Step{
+			{
+				Name: "invoice",
+				On:   "order.created",
+				Action: func(ctx context.Context, st *State, ev pubsub.Event) error {
+					st.Data["invoice"] = "INV-" + ev.Data.(string)
+					c.add("invoice")
+					return bus.Publish(ctx, "invoice.generated", st.Data["invoice"])
+				},
+				Compensate: func(ctx context.Context, st *State) error {
+					c.add("cancel " + st.Data["invoice"])
+					return nil
+				},
+			},
+			{
+				Name: "mail",
+				On:   "invoice.generated",
+				Action: func(ctx context.Context, st *State, ev pubsub.Event) error {
+					c.add("mail " + ev.Data.(string))
+					return mailErr
+				},
+			},
+		},
+		FailOn:  []pubsub.Topic{"order.canceled"},
+		Timeout: time.Minute,
+	}
+}
+
+func start(t *testing.T, bus pubsub.Bus, clock pubsub.Clock, defs ...Definition) *Orchestrator {
+	t.Helper()
+	o := New(bus, NewMemoryStore(), Options{Clock: clock, Logger: log.New(io.Discard, "", 0), Buffer: 8})
+	for _, d := range defs {
+		if err := o.Register(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := o.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = o.Wait()
+	})
+	return o
+}
+
+func waitStatus(t *testing.T, o *Orchestrator, id string, want Status) State {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		st, err := o.Get(context.Background(), id)
+		if err == nil && st.Status == want {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("saga %s: status = %v (err %v), want %v", id, st.Status, err, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func publishOrder(t *testing.T, bus pubsub.Bus, id string) {
+	t.Helper()
+	ctx := pubsub.WithCorrelationID(context.Background(), id)
+	if err := bus.Publish(ctx, "order.created", id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSagaCompletes(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	c := &calls{}
+	o := start(t, bus, newFakeClock(), orderSaga(bus, c, nil))
+
+	publishOrder(t, bus, "ORD-1")
+	st := waitStatus(t, o, "ORD-1", StatusCompleted)
+	if !slices.Equal(st.Completed, []string{"invoice", "mail"}) || st.Data["invoice"] != "INV-ORD-1" {
+		t.Fatalf("state = %+v", st)
+	}
+	if got := c.get(); !slices.Equal(got, []string{"invoice", "mail INV-ORD-1"}) {
+		t.Fatalf("calls = %v", got)
+	}
+
+	// event ซ้ำของ saga ที่จบแล้วถูกเพิกเฉย
+	publishOrder(t, bus, "ORD-1")
+	time.Sleep(20 * time.Millisecond)
+	if got := len(c.get()); got != 2 {
+		t.Fatalf("calls after duplicate = %d, want 2", got)
+	}
+}
+
+func TestSagaCompensatesFailedStep(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	c := &calls{}
+	o := start(t, bus, newFakeClock(), orderSaga(bus, c, errors.New("smtp down")))
+
+	publishOrder(t, bus, "ORD-1")
+	st := waitStatus(t, o, "ORD-1", StatusCompensated)
+	if !strings.Contains(st.Reason, "mail") || !slices.Equal(st.Compensated, []string{"invoice"}) {
+		t.Fatalf("state = %+v", st)
+	}
+	if got := c.get(); !slices.Equal(got, []string{"invoice", "mail INV-ORD-1", "cancel INV-ORD-1"}) {
+		t.Fatalf("calls = %v", got)
+	}
+}
+
+func TestSagaRetriesFailedActionBeforeCompensating(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	c := &calls{}
+	def := orderSaga(bus, c, nil)
+	failures := 2
+	def.Steps[1].Action = func(ctx context.Context, st *State, ev pubsub.Event) error {
+		c.add("mail " + ev.Data.(string))
+		if failures > 0 {
+			failures--
+			return errors.New("smtp busy")
+		}
+		return nil
+	}
+	o := New(bus, NewMemoryStore(), Options{
+		Clock:      newFakeClock(),
+		Logger:     log.New(io.Discard, "", 0),
+		Subscriber: []subscriber.Option{subscriber.WithRetry(subscriber.RetryPolicy{MaxAttempts: 3})},
+	})
+	if err := o.Register(def); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); _ = o.Wait() }()
+	if err := o.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	publishOrder(t, bus, "ORD-1")
+	st := waitStatus(t, o, "ORD-1", StatusCompleted)
+	if len(st.Compensated) != 0 {
+		t.Fatalf("state = %+v", st)
+	}
+	want := []string{"invoice", "mail INV-ORD-1", "mail INV-ORD-1", "mail INV-ORD-1"}
+	if got := c.get(); !slices.Equal(got, want) {
+		t.Fatalf("calls = %v", got)
+	}
+}
+
+func TestSagaFailOnEvent(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	c := &calls{}
+	def := orderSaga(bus, c, nil)
+	def.Steps[1].On = "mail.requested" // ไม่มีใคร publish saga จึงค้างที่ step แรก
+	o := start(t, bus, newFakeClock(), def)
+
+	publishOrder(t, bus, "ORD-1")
+	waitStatus(t, o, "ORD-1", StatusRunning)
+	ctx := pubsub.WithCorrelationID(context.Background(), "ORD-1")
+	_ = bus.Publish(ctx, "order.canceled", "ORD-1")
+
+	st := waitStatus(t, o, "ORD-1", StatusCompensated)
+	if st.Reason != "event order.canceled" {
+		t.Fatalf("reason = %q", st.Reason)
+	}
+}
+
+func TestSagaTimeout(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	clock := newFakeClock()
+	c := &calls{}
+	def := orderSaga(bus, c, nil)
+	def.Steps[0].Compensate = func(ctx context.Context, st *State) error {
+		c.add("cancel")
+		return errors.New("billing down")
+	}
+	def.Steps[1].On = "mail.requested"
+	o := start(t, bus, clock, def)
+
+	publishOrder(t, bus, "ORD-1")
+	st := waitStatus(t, o, "ORD-1", StatusRunning)
+	if !st.Deadline.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("deadline = %v", st.Deadline)
+	}
+	clock.Advance(59 * time.Second)
+	if st, _ := o.Get(context.Background(), "ORD-1"); st.Status != StatusRunning {
+		t.Fatalf("status before timeout = %v", st.Status)
+	}
+	clock.Advance(time.Second)
+	st = waitStatus(t, o, "ORD-1", StatusCompensationFailed)
+	if !strings.HasPrefix(st.Reason, "timeout; compensate invoice") {
+		t.Fatalf("reason = %q", st.Reason)
+	}
+
+	// event ที่มาช้าหลัง timeout ไม่ทำให้ saga กลับมาทำงาน
+	ctx := pubsub.WithCorrelationID(context.Background(), "ORD-1")
+	_ = bus.Publish(ctx, "mail.requested", "INV-ORD-1")
+	time.Sleep(20 * time.Millisecond)
+	if got := c.get(); !slices.Equal(got, []string{"invoice", "cancel"}) {
+		t.Fatalf("calls = %v", got)
+	}
+}
+
+func TestStartRestoresTimeouts(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	now := clock.Now()
+	_ = store.Put(context.Background(), State{ID: "A", Saga: "order", Status: StatusRunning, StartedAt: now, Deadline: now.Add(time.Second)})
+	_ = store.Put(context.Background(), State{ID: "B", Saga: "order", Status: StatusCompleted, StartedAt: now.Add(time.Millisecond)})
+
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+	o := New(bus, store, Options{Clock: clock, Logger: log.New(io.Discard, "", 0)})
+	if err := o.Register(orderSaga(bus, &calls{}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); _ = o.Wait() }()
+	if err := o.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Register(Definition{Name: "late", Steps: []Step{{Name: "x", On: "x"}}}); !errors.Is(err, ErrStarted) {
+		t.Fatalf("register after start = %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if st, _ := o.Get(context.Background(), "A"); st.Status != StatusCompensated || st.Reason != "timeout" {
+		t.Fatalf("A = %+v", st)
+	}
+	all, _ := o.List(context.Background(), Query{Saga: "order"})
+	if len(all) != 2 || all[0].ID != "A" || all[1].ID != "B" {
+		t.Fatalf("list = %+v", all)
+	}
+	if _, err := o.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing = %v", err)
+	}
+}
+
+func TestDefinitionValidate(t *testing.T) {
+	for _, def := range []Definition{
+		{},
+		{Name: "a"},
+		{Name: "a", Steps: []Step{{Name: "x"}}},
+		{Name: "a", Steps: []Step{{Name: "x", On: "t1"}, {Name: "x", On: "t2"}}},
+		{Name: "a", Steps: []Step{{Name: "x", On: "t1"}, {Name: "y", On: "t1"}}},
+	} {
+		if err := def.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", def)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package saga
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrNotFound คืนจาก Store.Get เมื่อไม่มี saga instance นั้น
+var ErrNotFound = errors.New("saga: not found")
+
+// Query เงื่อนไขของ List (ค่าว่าง = ไม่กรอง)
+type Query struct {
+	Saga   string
+	Status Status
+}
+
+func (q Query) match(st State) bool {
+	return (q.Saga == "" || q.Saga == st.Saga) && (q.Status == 0 || q.Status == st.Status)
+}
+
+// Store เก็บสถานะของ saga instance
+// Orchestrator เรียก Get/Put ของ instance เดียวกันทีละตัวเสมอ implementation จึงไม่ต้องล็อกต่อ instance
+// แต่ถ้ามีหลาย process ใช้ store เดียวกัน ควรให้แต่ละ saga ถูกจัดการโดย process เดียว
+type Store interface {
+	Get(ctx context.Context, id string) (State, error)
+	Put(ctx context.Context, st State) error
+	List(ctx context.Context, q Query) ([]State, error)
+}
+
+type memoryStore struct {
+	mu     sync.RWMutex
+	states map[string]State
+}
+
+// NewMemoryStore สร้าง Store ในหน่วยความจำ (หายเมื่อ process จบ)
+func NewMemoryStore() Store {
+	return &memoryStore{states: make(map[string]State)}
+}
+
+func (m *memoryStore) Get(_ context.Context, id string) (State, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st, ok := m.states[id]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return st.clone(), nil
+}
+
+func (m *memoryStore) Put(_ context.Context, st State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[st.ID] = st.clone()
+	return nil
+}
+
+// List คืน instance ที่ตรงเงื่อนไข เรียงตามเวลาที่เริ่ม
+func (m *memoryStore) List(_ context.Context, q Query) ([]State, error) {
+	m.mu.RLock()
+	var out []State
+	for _, st := range m.states {
+		if q.match(st) {
+			out = append(out, st.clone())
+		}
+	}
+	m.mu.RUnlock()
+	slices.SortFunc(out, func(a, b State) int {
+		if c := a.StartedAt.Compare(b.StartedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return out, nil
+}
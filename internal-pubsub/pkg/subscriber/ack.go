@@ -0,0 +1,94 @@
+package subscriber
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// การตัดสิน event ของ handler (มีผลเมื่อ subscription สมัครด้วย pubsub.WithAck ผ่าน WithSubscribeOptions)
+// ถ้า handler ไม่ได้ตัดสินเอง: คืน nil = Ack, คืน error = ไม่ Ack ให้ bus ส่งใหม่เมื่อหมด visibility
+// ครั้งที่ส่ง (Event.Attempt) อ่านได้จาก ev หรือ pubsub.EventFromContext(ctx)
+
+type settleAction int
+
+const (
+	settleNone settleAction = iota
+	settleAck
+	settleNack
+	settleTerminate
+)
+
+type settlement struct {
+	mu     sync.Mutex
+	action settleAction
+	delay  time.Duration
+}
+
+type settlementKey struct{}
+
+// Ack ยืนยัน event ปัจจุบันทันทีที่ handler คืนค่า แม้ handler จะคืน error
+func Ack(ctx context.Context) { decide(ctx, settleAck, 0) }
+
+// Nack ขอให้ส่ง event ปัจจุบันใหม่หลัง delay โดยไม่ retry ภายใน process และไม่ส่ง dead-letter
+func Nack(ctx context.Context, delay time.Duration) { decide(ctx, settleNack, delay) }
+
+// Terminate เลิกส่ง event ปัจจุบันโดยไม่ retry และไม่ส่ง dead-letter
+func Terminate(ctx context.Context) { decide(ctx, settleTerminate, 0) }
+
+func decide(ctx context.Context, a settleAction, delay time.Duration) {
+	st, _ := ctx.Value(settlementKey{}).(*settlement)
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.action, st.delay = a, delay
+}
+
+func withSettlement(ctx context.Context) (context.Context, *settlement) {
+	st := &settlement{}
+	return context.WithValue(ctx, settlementKey{}, st), st
+}
+
+// decided บอกว่า handler ของ event ปัจจุบันสั่ง Nack หรือ Terminate แล้ว (ไม่ควร retry ต่อ)
+func decided(ctx context.Context) bool {
+	st, _ := ctx.Value(settlementKey{}).(*settlement)
+	if st == nil {
+		return false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.action == settleNack || st.action == settleTerminate
+}
+
+// handle ประมวลผล event หนึ่งตัวแล้วตัดสินกับ bus ตามที่ handler สั่ง (หรือตามผลถ้าไม่ได้สั่ง)
+func (s *Subscriber) handle(ctx context.Context, handler Handler, ev pubsub.Event) error {
+	ctx, st := withSettlement(ctx)
+	err := s.process(ctx, handler, ev)
+	s.settle(ev, st, err)
+	return err
+}
+
+func (s *Subscriber) settle(ev pubsub.Event, st *settlement, err error) {
+	acker, ok := s.sub.(pubsub.Acker)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	action, delay := st.action, st.delay
+	st.mu.Unlock()
+	if action == settleNone && err == nil {
+		action = settleAck
+	}
+	switch action {
+	case settleAck:
+		acker.Ack(ev)
+	case settleNack:
+		acker.Nack(ev, delay)
+	case settleTerminate:
+		acker.Terminate(ev)
+	}
+}
@@ -0,0 +1,83 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+func TestHandlerSettlesEvents(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	sub := New(bus, "order.created", 4, quiet,
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithSubscribeOptions(pubsub.WithAck(20*time.Millisecond)),
+	)
+	defer sub.Close()
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string][]int{}
+		done     = make(chan struct{}, 8)
+	)
+	go sub.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+		mu.Lock()
+		attempts[ev.Data.(string)] = append(attempts[ev.Data.(string)], ev.Attempt)
+		mu.Unlock()
+		defer func() { done <- struct{}{} }()
+		switch ev.Data {
+		case "nack":
+			if ev.Attempt == 1 {
+				Nack(ctx, 0)
+				return errors.New("not yet")
+			}
+		case "fail":
+			// ไม่ตัดสินเอง: retry ภายใน process ก่อน แล้วรอ bus ส่งใหม่เมื่อหมด visibility
+			if ev.Attempt == 1 {
+				return errors.New("boom")
+			}
+		case "terminate":
+			Terminate(ctx)
+			return errors.New("poison")
+		}
+		return nil
+	})
+
+	for _, data := range []string{"nack", "fail", "terminate"} {
+		_ = bus.Publish(context.Background(), "order.created", data)
+	}
+	for range 2 + 4 + 1 {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("handler not called enough times: %v", attempts)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string][]int{"nack": {1, 2}, "fail": {1, 1, 1, 2}, "terminate": {1}}
+	for k, w := range want {
+		if got := attempts[k]; !slices.Equal(got, w) {
+			t.Fatalf("attempts[%s] = %v, want %v", k, got, w)
+		}
+	}
+	// การตัดสินเกิดหลัง handler คืนค่า
+	deadline := time.Now().Add(time.Second)
+	for {
+		st := bus.(pubsub.Inspector).Stats().Subscriptions[0]
+		if st.Unacked == 0 && st.Redelivered == 2 && st.Terminated == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,128 @@
+package subscriber
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// RunConcurrent เหมือน Run แต่ประมวลผลด้วย worker จำนวน workers ตัวพร้อมกัน
+// ถ้ากำหนด KeyFunc ไว้ event ที่ key เดียวกันจะถูกประมวลผลตามลำดับโดย worker ตัวเดียวกัน
+// middleware (รวม BaseMiddleware ที่ recover panic), retry, dead-letter และ StopOnError ทำงานเหมือน Run ทุกประการ
+// เมื่อจบจะรอให้ทุก worker ทำ event ที่ถืออยู่เสร็จก่อนค่อยคืนค่า
+// event ที่รับมาแล้วแต่ยังไม่เริ่มประมวลผลตอน ctx ถูกยกเลิกจะถูกคืนด้วย release
+func (s *Subscriber) RunConcurrent(ctx context.Context, workers int, handler Handler) error {
+	if workers <= 1 {
+		return s.Run(ctx, handler)
+	}
+	handler = s.wrap(handler)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	queues := s.workerQueues(workers)
+	var wg sync.WaitGroup
+	for _, q := range queues {
+		wg.Add(1)
+		go func(q <-chan pubsub.Event) {
+			defer wg.Done()
+			for ev := range q {
+				if runCtx.Err() != nil {
+					s.release(ev)
+					continue
+				}
+				err := s.handle(runCtx, handler, ev)
+				s.markDone(ev)
+				if err != nil && s.opts.StopOnError {
+					fail(err)
+				}
+			}
+		}(q)
+	}
+
+	s.dispatch(runCtx, queues)
+
+	// ปิด queue (ตัวที่ซ้ำกันปิดครั้งเดียว) แล้วรอ worker จบ
+	closed := make(map[chan pubsub.Event]struct{}, len(queues))
+	for _, q := range queues {
+		if _, ok := closed[q]; !ok {
+			close(q)
+			closed[q] = struct{}{}
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// workerQueues คืน queue ของแต่ละ worker
+// ไม่มี KeyFunc: ทุก worker ใช้ queue เดียวกัน / มี KeyFunc: แยก queue ต่อ worker
+func (s *Subscriber) workerQueues(workers int) []chan pubsub.Event {
+	queues := make([]chan pubsub.Event, workers)
+	if s.opts.KeyFunc == nil {
+		shared := make(chan pubsub.Event)
+		for i := range queues {
+			queues[i] = shared
+		}
+		return queues
+	}
+	for i := range queues {
+		queues[i] = make(chan pubsub.Event, 1)
+	}
+	return queues
+}
+
+// dispatch อ่านจาก subscription แล้วกระจายเข้า queue จนกว่า ctx ถูกยกเลิกหรือ channel ถูกปิด
+func (s *Subscriber) dispatch(ctx context.Context, queues []chan pubsub.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-s.sub.C():
+			if !ok {
+				return
+			}
+			q := queues[0]
+			if s.opts.KeyFunc != nil {
+				q = queues[shard(s.opts.KeyFunc(ev), len(queues))]
+			}
+			select {
+			case q <- ev:
+			case <-ctx.Done():
+				s.release(ev)
+				return
+			}
+		}
+	}
+}
+
+// release คืน event ที่รับจาก subscription แล้วแต่ไม่ได้ประมวลผลเพราะกำลังหยุด
+// MarkDone เพื่อไม่ให้ bus.Close รอ event นี้ และ Nack ให้ส่งใหม่ถ้าสมัครด้วย pubsub.WithAck
+func (s *Subscriber) release(ev pubsub.Event) {
+	if acker, ok := s.sub.(pubsub.Acker); ok {
+		acker.Nack(ev, 0)
+	}
+	s.markDone(ev)
+}
+
+func shard(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,160 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// Middleware ห่อ Handler เพื่อเพิ่มพฤติกรรมก่อนและหลังการประมวลผล event
+// ลงทะเบียนด้วย WithMiddleware ทำงานรอบ handler ทุกครั้งที่ถูกเรียก (รวมแต่ละครั้งที่ retry)
+type Middleware func(Handler) Handler
+
+// Chain รวม middleware หลายตัวเป็นตัวเดียว ตัวแรกอยู่นอกสุด (เห็น event ก่อนและเห็นผลหลังสุด)
+func Chain(mws ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			if mws[i] != nil {
+				h = mws[i](h)
+			}
+		}
+		return h
+	}
+}
+
+// PanicError คือ error ที่ได้เมื่อ handler panic
+type PanicError struct {
+	Value any    // ค่าที่ส่งให้ panic
+	Stack []byte // stack ตอน panic
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("handler panic: %v", e.Value) }
+
+// Recover แปลง panic ของ handler (และ middleware ที่อยู่ด้านใน) เป็น *PanicError
+// เป็นส่วนหนึ่งของ BaseMiddleware ค่าเริ่มต้น ใส่ซ้ำด้านในของ chain ได้เมื่อต้องการให้ middleware
+// ที่อยู่ด้านนอก (เช่น Logging, Metrics, Trace) เห็น panic เป็น error ธรรมดา
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, ev)
+		}
+	}
+}
+
+// ErrorLog บันทึก error ของ handler ด้วย log.Logger (nil = log.Default()) ทุกครั้งที่ล้มเหลว
+// ถ้าเป็น panic จะพิมพ์ stack ด้วย เป็นส่วนหนึ่งของ BaseMiddleware ค่าเริ่มต้น (ใช้ Options.Logger)
+func ErrorLog(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) error {
+			err := next(ctx, ev)
+			var perr *PanicError
+			switch {
+			case errors.As(err, &perr):
+				logger.Printf("[subscriber] panic recovered topic=%s id=%s err=%v\n%s", ev.Topic, ev.ID, err, perr.Stack)
+			case err != nil:
+				logger.Printf("[subscriber] handler error topic=%s id=%s err=%v", ev.Topic, ev.ID, err)
+			}
+			return err
+		}
+	}
+}
+
+// Timeout จำกัดเวลาประมวลผล event ละไม่เกิน d ผ่าน ctx (handler ต้องเคารพ ctx เอง)
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, ev)
+		}
+	}
+}
+
+// Logging บันทึกผลของทุก event แบบมีโครงสร้างด้วย slog (nil = slog.Default())
+// ใช้แทน ErrorLog ได้ด้วย WithBaseMiddleware(Logging(l), Recover()) เพื่อไม่ให้ log error ซ้ำ
+// สำเร็จบันทึกที่ระดับ Debug ล้มเหลวที่ระดับ Error พร้อม topic, id, correlation id และเวลาที่ใช้
+func Logging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) error {
+			start := time.Now()
+			err := next(ctx, ev)
+			attrs := []slog.Attr{
+				slog.String("topic", string(ev.Topic)),
+				slog.String("event_id", ev.ID),
+				slog.String("correlation_id", ev.CorrelationID()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("err", err))
+				logger.LogAttrs(ctx, slog.LevelError, "handler failed", attrs...)
+			} else {
+				logger.LogAttrs(ctx, slog.LevelDebug, "handler done", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// MetricsRecorder รับผลของทุก event เพื่อส่งต่อให้ระบบ metrics (เช่น Prometheus histogram/counter)
+type MetricsRecorder interface {
+	ObserveHandler(ev pubsub.Event, d time.Duration, err error)
+}
+
+// MetricsFunc ใช้ฟังก์ชันธรรมดาเป็น MetricsRecorder
+type MetricsFunc func(ev pubsub.Event, d time.Duration, err error)
+
+func (f MetricsFunc) ObserveHandler(ev pubsub.Event, d time.Duration, err error) { f(ev, d, err) }
+
+// Metrics วัดเวลาและผลของทุก event แล้วส่งให้ m
+func Metrics(m MetricsRecorder) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) error {
+			start := time.Now()
+			err := next(ctx, ev)
+			m.ObserveHandler(ev, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Tracer เชื่อมกับระบบ tracing (เช่น OpenTelemetry) โดย package นี้ไม่ต้องพึ่ง library ใด
+type Tracer interface {
+	// Start เริ่ม span ของ event คืน ctx ที่มี span อยู่ และฟังก์ชันปิด span ที่ถูกเรียกเมื่อ handler จบ
+	Start(ctx context.Context, ev pubsub.Event) (context.Context, func(err error))
+}
+
+// TracerFunc ใช้ฟังก์ชันธรรมดาเป็น Tracer
+type TracerFunc func(ctx context.Context, ev pubsub.Event) (context.Context, func(err error))
+
+func (f TracerFunc) Start(ctx context.Context, ev pubsub.Event) (context.Context, func(err error)) {
+	return f(ctx, ev)
+}
+
+// Trace เปิด span รอบ handler ด้วย t (ctx ที่ได้ถูกส่งต่อให้ handler และ Publish ภายใน handler)
+func Trace(t Tracer) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev pubsub.Event) error {
+			ctx, end := t.Start(ctx, ev)
+			err := next(ctx, ev)
+			end(err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package subscriber
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+type ctxKey string
+
+func TestMiddlewareRunsInOrderAroundHandler(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	var trace []string
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, ev pubsub.Event) error {
+				trace = append(trace, name+">")
+				err := next(context.WithValue(ctx, ctxKey(name), true), ev)
+				trace = append(trace, "<"+name)
+				return err
+			}
+		}
+	}
+	sub := New(bus, "order.created", 1, quiet,
+		WithMiddleware(mark("auth")),
+		WithMiddleware(mark("log"), nil),
+	)
+	defer sub.Close()
+
+	handled := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+			if ctx.Value(ctxKey("auth")) == nil || ctx.Value(ctxKey("log")) == nil {
+				t.Error("handler ctx misses middleware values")
+			}
+			trace = append(trace, "handler")
+			close(handled)
+			return nil
+		})
+	}()
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+	<-handled
+	sub.Close()
+	<-done // Run จบหลัง middleware ด้านนอกคืนค่าแล้ว
+
+	if got := strings.Join(trace, " "); got != "auth> log> handler <log <auth" {
+		t.Fatalf("trace = %q", got)
+	}
+}
+
+func TestBuiltinMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var (
+		observed []error
+		spans    []string
+	)
+	metrics := MetricsFunc(func(ev pubsub.Event, d time.Duration, err error) { observed = append(observed, err) })
+	tracer := TracerFunc(func(ctx context.Context, ev pubsub.Event) (context.Context, func(error)) {
+		spans = append(spans, "start "+string(ev.Topic))
+		return ctx, func(err error) { spans = append(spans, "end "+errString(err)) }
+	})
+
+	h := Chain(Logging(logger), Metrics(metrics), Trace(tracer), Timeout(time.Second), Recover())(
+		func(ctx context.Context, ev pubsub.Event) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			if ev.Data == "panic" {
+				panic("boom")
+			}
+			return nil
+		})
+
+	ev := pubsub.NewEvent(context.Background(), "order.created", "ORD-1")
+	if err := h(context.Background(), ev); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	ev.Data = "panic"
+	err := h(context.Background(), ev)
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Fatalf("err = %v, want PanicError(boom)", err)
+	}
+
+	if len(observed) != 2 || observed[0] != nil || observed[1] != err {
+		t.Fatalf("metrics observed %v", observed)
+	}
+	if got := strings.Join(spans, ", "); got != "start order.created, end ok, start order.created, end handler panic: boom" {
+		t.Fatalf("spans = %q", got)
+	}
+	logs := buf.String()
+	for _, want := range []string{`"msg":"handler done"`, `"msg":"handler failed"`, `"event_id":"` + ev.ID + `"`, `"err":"handler panic: boom"`} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("logs missing %s:\n%s", want, logs)
+		}
+	}
+}
+
+func TestTimeoutMiddlewareCancelsSlowHandler(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(func(ctx context.Context, ev pubsub.Event) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := h(context.Background(), pubsub.Event{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
+
+func TestBaseMiddlewareDefaultsAndReplacement(t *testing.T) {
+	run := func(t *testing.T, opts ...Option) {
+		t.Helper()
+		bus := pubsub.New(pubsub.DefaultOptions())
+		defer bus.Close(context.Background())
+		sub := New(bus, "order.created", 2, opts...)
+		defer sub.Close()
+
+		handled := make(chan struct{}, 2)
+		done := make(chan error, 1)
+		go func() {
+			done <- sub.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+				defer func() { handled <- struct{}{} }()
+				if ev.Data == "panic" {
+					panic("boom")
+				}
+				return errors.New("declined")
+			})
+		}()
+		_ = bus.Publish(context.Background(), "order.created", "panic")
+		_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+		<-handled
+		<-handled
+		sub.Close()
+		if err := <-done; err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+
+	var std bytes.Buffer
+	run(t, WithLogger(log.New(&std, "", 0)))
+	if got := std.String(); !strings.Contains(got, "panic recovered topic=order.created") ||
+		strings.Count(got, "handler error topic=order.created") != 1 {
+		t.Fatalf("default logs:\n%s", got)
+	}
+
+	std.Reset()
+	var structured bytes.Buffer
+	run(t, WithLogger(log.New(&std, "", 0)),
+		WithBaseMiddleware(Logging(slog.New(slog.NewTextHandler(&structured, nil))), Recover()))
+	if std.Len() != 0 {
+		t.Fatalf("replaced ErrorLog still logged:\n%s", std.String())
+	}
+	if got := strings.Count(structured.String(), "handler failed"); got != 2 {
+		t.Fatalf("structured logs = %d failures, want 2:\n%s", got, structured.String())
+	}
+}
@@ -1,6 +1,10 @@
 package subscriber
 
-import "log"
+import (
+	"log"
+
+	"internal-pubsub/pkg/pubsub"
+)
 
 type Options struct {
 	// ถ้า true: เมื่อ handler คืน error ให้หยุด Run() (รีเทิร์น error กลับไป)
@@ -9,6 +13,28 @@ type Options struct {
 
 	// Logger (ค่าเริ่มต้นใช้ log.Default())
 	Logger *log.Logger
+
+	// Retry นโยบายลองใหม่ (ค่าเริ่มต้นไม่ retry)
+	Retry RetryPolicy
+
+	// DeadLetterTopic ถ้าไม่ว่าง: event ที่ล้มเหลวครบทุกครั้งจะถูก publish ไปที่ topic นี้บน bus เดิม
+	// ในรูป DeadLetter และถือว่าจัดการแล้ว (ไม่ทำให้ Run หยุดแม้ StopOnError == true)
+	DeadLetterTopic pubsub.Topic
+
+	// KeyFunc ใช้กับ RunConcurrent: event ที่ได้ key เดียวกันจะไปยัง worker เดียวกันเสมอ (คงลำดับต่อ key)
+	// ถ้า nil: worker ที่ว่างตัวแรกจะหยิบ event ไป (ไม่รับประกันลำดับ)
+	KeyFunc func(pubsub.Event) string
+
+	// BaseMiddleware ห่อชั้นนอกสุดก่อน Middleware
+	// nil = ค่าเริ่มต้น ErrorLog(Logger) แล้ว Recover() ใช้ WithBaseMiddleware เพื่อแทนที่หรือเอาออก
+	// (ถ้าเอา Recover ออก panic ของ handler จะไม่ถูกจับ)
+	BaseMiddleware []Middleware
+
+	// Middleware ห่อ handler ตามลำดับ (ตัวแรกอยู่นอกสุด) อยู่ด้านในของ BaseMiddleware ดู WithMiddleware
+	Middleware []Middleware
+
+	// SubscribeOptions ส่งต่อให้ bus.Subscribe เช่น override โหมดการส่งของ subscription นี้
+	SubscribeOptions []pubsub.SubscribeOption
 }
 
 func DefaultOptions() Options {
@@ -31,3 +57,31 @@ func WithLogger(l *log.Logger) Option {
 		}
 	}
 }
+
+func WithRetry(p RetryPolicy) Option {
+	return func(o *Options) { o.Retry = p }
+}
+
+func WithDeadLetter(topic pubsub.Topic) Option {
+	return func(o *Options) { o.DeadLetterTopic = topic }
+}
+
+func WithKeyFunc(fn func(pubsub.Event) string) Option {
+	return func(o *Options) { o.KeyFunc = fn }
+}
+
+func WithSubscribeOptions(opts ...pubsub.SubscribeOption) Option {
+	return func(o *Options) { o.SubscribeOptions = append(o.SubscribeOptions, opts...) }
+}
+
+// WithMiddleware เพิ่ม middleware ต่อท้ายตัวที่มีอยู่ (ตัวที่เพิ่มก่อนอยู่นอกกว่า)
+// เช่น WithMiddleware(Metrics(m), Timeout(5*time.Second))
+func WithMiddleware(mws ...Middleware) Option {
+	return func(o *Options) { o.Middleware = append(o.Middleware, mws...) }
+}
+
+// WithBaseMiddleware แทนที่ BaseMiddleware ค่าเริ่มต้น (ไม่ส่งอะไรเลย = ไม่มี)
+// เช่น WithBaseMiddleware(Logging(slogger), Recover()) เพื่อ log แบบมีโครงสร้างแทน ErrorLog
+func WithBaseMiddleware(mws ...Middleware) Option {
+	return func(o *Options) { o.BaseMiddleware = append([]Middleware{}, mws...) }
+}
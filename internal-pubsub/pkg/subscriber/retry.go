@@ -0,0 +1,81 @@
+package subscriber
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// RetryPolicy กำหนดการลองใหม่เมื่อ handler คืน error
+type RetryPolicy struct {
+	// จำนวนครั้งสูงสุดที่เรียก handler ต่อ event (<= 1 คือไม่ retry)
+	MaxAttempts int
+	// ระยะรอก่อน retry ครั้งแรก และเพดานของระยะรอ
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// ตัวคูณของ backoff ในแต่ละครั้ง (<= 1 ใช้ 2)
+	Multiplier float64
+	// สัดส่วนความคลาดเคลื่อนแบบสุ่ม 0..1 เช่น 0.2 คือ ±20%
+	Jitter float64
+	// Retryable ตัดสินว่า error นี้ควร retry หรือไม่ (nil = retry ทุก error)
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy ค่าแนะนำ: ลอง 3 ครั้ง เริ่ม 100ms สูงสุด 5s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// backoff คำนวณระยะรอหลังจากครั้งที่ attempt (เริ่มที่ 1) ล้มเหลว
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult <= 1 {
+		mult = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(mult, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
+
+// DeadLetter คือ payload ที่ส่งไปยัง dead-letter topic เมื่อ retry ครบแล้วยังล้มเหลว
+type DeadLetter struct {
+	Event    pubsub.Event // event ต้นฉบับ
+	Error    string       // error ล่าสุดจาก handler
+	Attempts int          // จำนวนครั้งที่เรียก handler ไปแล้ว
+}
+
+// sleepCtx รอ d หรือจนกว่า ctx จะถูกยกเลิก
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
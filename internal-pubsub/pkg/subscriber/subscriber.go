@@ -2,15 +2,30 @@ package subscriber
 
 import (
 	"context"
-	"fmt"
 	"internal-pubsub/pkg/pubsub"
-	"runtime/debug"
+	"slices"
 	"sync"
 )
 
 // Handler ฟังก์ชันประมวลผล event แบบทีละรายการ
 type Handler func(ctx context.Context, ev pubsub.Event) error
 
+// TypedHandler ฟังก์ชันประมวลผลที่รับ payload ชนิด T โดยตรง
+// อ่าน metadata ของ event ได้จาก pubsub.EventFromContext(ctx)
+type TypedHandler[T any] func(ctx context.Context, data T) error
+
+// Typed แปลง TypedHandler เป็น Handler สำหรับใช้กับ Run
+// ถ้า payload ไม่ใช่ T จะคืน *pubsub.TypeMismatchError (ไม่ panic) และเข้าสู่นโยบาย error ของ Run ตามปกติ
+func Typed[T any](h TypedHandler[T]) Handler {
+	return func(ctx context.Context, ev pubsub.Event) error {
+		data, err := pubsub.DataAs[T](ev)
+		if err != nil {
+			return err
+		}
+		return h(ctx, data)
+	}
+}
+
 // Subscriber ดูแล lifecycle ของ subscription
 type Subscriber struct {
 	bus    pubsub.Bus
@@ -27,10 +42,15 @@ func New(bus pubsub.Bus, topic pubsub.Topic, buffer int, optFns ...Option) *Subs
 	for _, f := range optFns {
 		f(&opts)
 	}
+	if opts.BaseMiddleware == nil {
+		opts.BaseMiddleware = []Middleware{ErrorLog(opts.Logger), Recover()}
+	}
+	// ติดตามการประมวลผล เพื่อให้ bus.Close ที่ drain (Options.DrainOnClose) รอ handler ที่กำลังทำงานจนเสร็จ
+	subOpts := append([]pubsub.SubscribeOption{pubsub.WithDoneTracking()}, opts.SubscribeOptions...)
 	return &Subscriber{
 		bus:   bus,
 		topic: topic,
-		sub:   bus.Subscribe(topic, buffer),
+		sub:   bus.Subscribe(topic, buffer, subOpts...),
 		opts:  opts,
 	}
 }
@@ -48,19 +68,9 @@ func (s *Subscriber) Close() {
 
 // Run จะอ่านจาก channel แบบ sequential และเรียก handler ทีละ event
 // จะจบเมื่อ ctx.Done() หรือ channel ถูกปิด หรือ handler คืน error (เมื่อ StopOnError == true)
+// ถ้าสมัครด้วย pubsub.WithAck ทุก event จะถูก Ack/Nack/Terminate ตามที่ handler สั่ง (ดู Ack)
 func (s *Subscriber) Run(ctx context.Context, handler Handler) error {
-	// ห่อ handler ให้ recover panic เสมอ
-	runHandler := func(ctx context.Context, ev pubsub.Event) (err error) {
-		defer func() {
-			if r := recover(); r != nil {
-				err = fmt.Errorf("handler panic: %v", r)
-				s.opts.Logger.Printf("[subscriber] panic recovered topic=%s err=%v\n%s",
-					s.topic, err, string(debug.Stack()))
-			}
-		}()
-		return handler(ctx, ev)
-	}
-
+	handler = s.wrap(handler)
 	for {
 		select {
 		case <-ctx.Done():
@@ -70,13 +80,65 @@ func (s *Subscriber) Run(ctx context.Context, handler Handler) error {
 				// channel ปิดจาก Unsubscribe() หรือ bus.Close()
 				return nil
 			}
-			if err := runHandler(ctx, ev); err != nil {
-				s.opts.Logger.Printf("[subscriber] handler error topic=%s err=%v", s.topic, err)
-				if s.opts.StopOnError {
-					return err
-				}
-				// ถ้าไม่หยุด ให้ continue อ่านตัวถัดไป
+			err := s.handle(ctx, handler, ev)
+			s.markDone(ev)
+			if err != nil && s.opts.StopOnError {
+				return err
 			}
+			// ถ้าไม่หยุด ให้ continue อ่านตัวถัดไป
 		}
 	}
 }
+
+// process เรียก handler ตามนโยบาย retry แล้วส่ง dead-letter ถ้ายังล้มเหลว
+// คืน error เมื่อ event นี้ถือว่าจัดการไม่สำเร็จ
+func (s *Subscriber) process(ctx context.Context, handler Handler, ev pubsub.Event) error {
+	policy := s.opts.Retry
+	attempts := 0
+	var err error
+	for {
+		attempts++
+		if err = s.invoke(ctx, handler, ev); err == nil {
+			return nil
+		}
+		if decided(ctx) {
+			// handler สั่ง Nack/Terminate เองแล้ว ให้ bus จัดการต่อ
+			return err
+		}
+		if attempts >= policy.MaxAttempts || !policy.retryable(err) {
+			break
+		}
+		if werr := sleepCtx(ctx, policy.backoff(attempts)); werr != nil {
+			return err
+		}
+	}
+
+	if s.opts.DeadLetterTopic == "" {
+		return err
+	}
+	dl := DeadLetter{Event: ev, Error: err.Error(), Attempts: attempts}
+	if perr := s.bus.Publish(pubsub.ContextWithEvent(ctx, ev), s.opts.DeadLetterTopic, dl); perr != nil {
+		s.opts.Logger.Printf("[subscriber] dead-letter publish failed topic=%s dlq=%s err=%v",
+			s.topic, s.opts.DeadLetterTopic, perr)
+		return err
+	}
+	return nil
+}
+
+// markDone แจ้ง bus ว่าประมวลผล ev เสร็จแล้ว
+func (s *Subscriber) markDone(ev pubsub.Event) {
+	if m, ok := s.sub.(pubsub.DoneMarker); ok {
+		m.MarkDone(ev)
+	}
+}
+
+// wrap ห่อ handler ด้วย BaseMiddleware แล้ว Middleware ของ Options
+func (s *Subscriber) wrap(handler Handler) Handler {
+	return Chain(append(slices.Clone(s.opts.BaseMiddleware), s.opts.Middleware...)...)(handler)
+}
+
+// invoke เรียก handler (ที่ห่อ middleware แล้ว) หนึ่งครั้ง
+func (s *Subscriber) invoke(ctx context.Context, handler Handler, ev pubsub.Event) error {
+	// ผูก event ไว้กับ ctx ให้ Publish ภายใน handler สืบทอด correlation/causation id
+	return handler(pubsub.ContextWithEvent(ctx, ev), ev)
+}
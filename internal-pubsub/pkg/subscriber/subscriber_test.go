@@ -0,0 +1,260 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+var quiet = WithLogger(log.New(io.Discard, "", 0))
+
+func TestRunRetriesThenDeadLetters(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	dlq := bus.Subscribe("order.created.dlq", 1)
+	sub := New(bus, "order.created", 1, quiet,
+		WithStopOnError(true),
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithDeadLetter("order.created.dlq"),
+	)
+	defer sub.Close()
+
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+			calls++
+			return errors.New("boom")
+		})
+	}()
+
+	if err := bus.Publish(context.Background(), "order.created", "ORD-1"); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case ev := <-dlq.C():
+		dl, ok := ev.Data.(DeadLetter)
+		if !ok {
+			t.Fatalf("expected DeadLetter, got %T", ev.Data)
+		}
+		if dl.Attempts != 3 || dl.Error != "boom" || dl.Event.Data != "ORD-1" {
+			t.Fatalf("unexpected dead letter %+v", dl)
+		}
+		if calls != 3 {
+			t.Fatalf("calls = %d, want 3", calls)
+		}
+	case err := <-done:
+		t.Fatalf("Run returned early: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for dead letter")
+	}
+}
+
+func TestRunSkipsRetryForNonRetryableError(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	errFatal := errors.New("fatal")
+	sub := New(bus, "order.created", 1, quiet,
+		WithStopOnError(true),
+		WithRetry(RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Millisecond,
+			Retryable:      func(err error) bool { return !errors.Is(err, errFatal) },
+		}),
+	)
+	defer sub.Close()
+
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+			calls++
+			return errFatal
+		})
+	}()
+	_ = bus.Publish(context.Background(), "order.created", "ORD-1")
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errFatal) || calls != 1 {
+			t.Fatalf("err = %v calls = %d", err, calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Run to stop")
+	}
+}
+
+type update struct {
+	OrderID string
+	Seq     int
+}
+
+func TestRunConcurrentKeepsPerKeyOrder(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+
+	sub := New(bus, "order.updated", 16, quiet,
+		WithKeyFunc(func(ev pubsub.Event) string { return ev.Data.(update).OrderID }),
+	)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.RunConcurrent(context.Background(), 4, func(ctx context.Context, ev pubsub.Event) error {
+			u := ev.Data.(update)
+			mu.Lock()
+			seen[u.OrderID] = append(seen[u.OrderID], u.Seq)
+			mu.Unlock()
+			return nil
+		})
+	}()
+
+	orders := []string{"ORD-1", "ORD-2", "ORD-3"}
+	for seq := range 20 {
+		for _, id := range orders {
+			if err := bus.Publish(context.Background(), "order.updated", update{OrderID: id, Seq: seq}); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+	}
+	sub.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunConcurrent: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for RunConcurrent")
+	}
+
+	for _, id := range orders {
+		got := seen[id]
+		if len(got) != 20 {
+			t.Fatalf("%s: got %d events, want 20", id, len(got))
+		}
+		for i, seq := range got {
+			if seq != i {
+				t.Fatalf("%s: out of order %v", id, got)
+			}
+		}
+	}
+}
+
+func TestRunConcurrentCancelReleasesTakenEvents(t *testing.T) {
+	bus := pubsub.New(drainOptions())
+	sub := New(bus, "order.updated", 4, quiet,
+		WithKeyFunc(func(ev pubsub.Event) string { return "same" }),
+	)
+	defer sub.Close()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var handled sync.WaitGroup
+	handled.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.RunConcurrent(ctx, 2, func(ctx context.Context, ev pubsub.Event) error {
+			defer handled.Done()
+			close(started)
+			<-unblock
+			return nil
+		})
+	}()
+	for i := range 3 {
+		_ = bus.Publish(context.Background(), "order.updated", i)
+	}
+	<-started
+	// รอจน dispatcher ดึงทุก event ออกจาก chan: ตัวที่ 2 ค้างใน queue ของ worker ตัวที่ 3 อยู่ในมือ dispatcher
+	for bus.(pubsub.Inspector).Stats().Subscriptions[0].Length > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(unblock)
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunConcurrent = %v, want context.Canceled", err)
+	}
+	handled.Wait()
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer closeCancel()
+	if err := bus.Close(closeCtx); err != nil {
+		t.Fatalf("close after cancel: %v", err)
+	}
+}
+
+func TestBusCloseDrainsInFlightHandlers(t *testing.T) {
+	bus := pubsub.New(drainOptions())
+
+	orders := New(bus, "order.created", 4, quiet)
+	invoices := New(bus, "invoice.generated", 4, quiet)
+
+	var mu sync.Mutex
+	mailed := 0
+	go orders.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+		time.Sleep(10 * time.Millisecond)
+		return bus.Publish(ctx, "invoice.generated", ev.Data)
+	})
+	go invoices.Run(context.Background(), func(ctx context.Context, ev pubsub.Event) error {
+		mu.Lock()
+		mailed++
+		mu.Unlock()
+		return nil
+	})
+
+	for i := range 3 {
+		_ = bus.Publish(context.Background(), "order.created", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bus.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if mailed != 3 {
+		t.Fatalf("mailed = %d, want 3", mailed)
+	}
+}
+
+func TestBusCloseReportsAbandoned(t *testing.T) {
+	bus := pubsub.New(drainOptions())
+	stuck := New(bus, "order.created", 4, quiet)
+	defer stuck.Close()
+
+	// ไม่มีใคร Run จึงไม่มีใครประมวลผล
+	for i := range 2 {
+		_ = bus.Publish(context.Background(), "order.created", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := bus.Close(ctx)
+	var drainErr *pubsub.DrainError
+	if !errors.As(err, &drainErr) || drainErr.Abandoned != 2 {
+		t.Fatalf("expected DrainError with 2 abandoned, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if err := bus.Publish(context.Background(), "order.created", 3); !errors.Is(err, pubsub.ErrClosed) {
+		t.Fatalf("publish after close = %v, want ErrClosed", err)
+	}
+}
+
+func drainOptions() pubsub.Options {
+	opts := pubsub.DefaultOptions()
+	opts.DrainOnClose = true
+	return opts
+}
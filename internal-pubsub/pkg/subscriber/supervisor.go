@@ -0,0 +1,181 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+// SupervisorOptions ปรับพฤติกรรมการ restart ของ Supervisor
+type SupervisorOptions struct {
+	// Backoff ระยะรอก่อน restart (ใช้ InitialBackoff/MaxBackoff/Multiplier/Jitter, ไม่ใช้ MaxAttempts)
+	Backoff RetryPolicy
+
+	// MaxRestarts จำนวน restart ติดกันสูงสุดก่อนยอมแพ้ (<= 0 คือไม่จำกัด)
+	MaxRestarts int
+
+	// ResetAfter ถ้า subscriber ทำงานได้นานกว่านี้ก่อนล้ม ให้เริ่มนับ restart และ backoff ใหม่
+	ResetAfter time.Duration
+
+	// Logger (ค่าเริ่มต้นใช้ log.Default())
+	Logger *log.Logger
+}
+
+// DefaultSupervisorOptions ค่าแนะนำ: restart ไม่จำกัด เริ่ม 100ms สูงสุด 10s นับใหม่เมื่อทำงานได้ 1 นาที
+func DefaultSupervisorOptions() SupervisorOptions {
+	return SupervisorOptions{
+		Backoff: RetryPolicy{
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.2,
+		},
+		ResetAfter: time.Minute,
+		Logger:     log.Default(),
+	}
+}
+
+type SupervisorOption func(*SupervisorOptions)
+
+func WithRestartBackoff(p RetryPolicy) SupervisorOption {
+	return func(o *SupervisorOptions) { o.Backoff = p }
+}
+
+func WithMaxRestarts(n int) SupervisorOption {
+	return func(o *SupervisorOptions) { o.MaxRestarts = n }
+}
+
+func WithResetAfter(d time.Duration) SupervisorOption {
+	return func(o *SupervisorOptions) { o.ResetAfter = d }
+}
+
+func WithSupervisorLogger(l *log.Logger) SupervisorOption {
+	return func(o *SupervisorOptions) {
+		if l != nil {
+			o.Logger = l
+		}
+	}
+}
+
+// Supervisor รวม subscriber หลายตัวให้เริ่ม หยุด และรอพร้อมกัน
+// subscriber ที่ Run คืน error (เช่นเมื่อ StopOnError == true) จะถูก restart ด้วย backoff
+// บน subscription เดิม จึงไม่เสีย event ที่ค้างอยู่ใน chan
+// subscriber จบโดยไม่ restart เมื่อ ctx ถูกยกเลิกหรือ channel ถูกปิด (Unsubscribe/bus.Close)
+type Supervisor struct {
+	bus  pubsub.Bus
+	opts SupervisorOptions
+
+	mu      sync.Mutex
+	members []*member
+	ctx     context.Context // ไม่เป็น nil หลัง Start
+	wg      sync.WaitGroup
+	errs    []error
+}
+
+// member คือ subscriber หนึ่งตัวที่ Supervisor ดูแล
+type member struct {
+	sub     *Subscriber
+	workers int
+	handler Handler
+}
+
+// NewSupervisor สร้าง Supervisor ที่ยังไม่เริ่มทำงาน
+func NewSupervisor(bus pubsub.Bus, optFns ...SupervisorOption) *Supervisor {
+	opts := DefaultSupervisorOptions()
+	for _, f := range optFns {
+		f(&opts)
+	}
+	return &Supervisor{bus: bus, opts: opts}
+}
+
+// Add ลงทะเบียน subscriber ที่ประมวลผลทีละ event (ดู Run) และ subscribe ทันที
+// ถ้า Supervisor เริ่มแล้ว subscriber จะเริ่มทำงานเลย
+func (s *Supervisor) Add(topic pubsub.Topic, buffer int, handler Handler, opts ...Option) *Subscriber {
+	return s.AddConcurrent(topic, buffer, 1, handler, opts...)
+}
+
+// AddConcurrent เหมือน Add แต่ประมวลผลด้วย worker จำนวน workers ตัว (ดู RunConcurrent)
+func (s *Supervisor) AddConcurrent(topic pubsub.Topic, buffer, workers int, handler Handler, opts ...Option) *Subscriber {
+	m := &member{sub: New(s.bus, topic, buffer, opts...), workers: workers, handler: handler}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members = append(s.members, m)
+	if s.ctx != nil {
+		s.launch(s.ctx, m)
+	}
+	return m.sub
+}
+
+// Start เริ่ม subscriber ทุกตัวที่ลงทะเบียนไว้ ยกเลิก ctx เพื่อหยุดทั้งหมด
+// เรียกซ้ำจะไม่มีผล
+func (s *Supervisor) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx = ctx
+	for _, m := range s.members {
+		s.launch(ctx, m)
+	}
+}
+
+// Wait รอจน subscriber ทุกตัวจบ แล้วคืน error ของตัวที่ยอมแพ้เพราะ restart ครบ (รวมด้วย errors.Join)
+func (s *Supervisor) Wait() error {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// Run คือ Start แล้ว Wait
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.Start(ctx)
+	return s.Wait()
+}
+
+// launch ต้องถือ s.mu
+func (s *Supervisor) launch(ctx context.Context, m *member) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer m.sub.Close()
+		if err := s.supervise(ctx, m); err != nil {
+			s.mu.Lock()
+			s.errs = append(s.errs, err)
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// supervise เรียก Run ของ member ซ้ำจนกว่าจะจบตามปกติหรือ restart ครบ
+func (s *Supervisor) supervise(ctx context.Context, m *member) error {
+	topic := m.sub.topic
+	restarts := 0
+	for {
+		began := time.Now()
+		err := m.sub.RunConcurrent(ctx, m.workers, m.handler)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if s.opts.ResetAfter > 0 && time.Since(began) >= s.opts.ResetAfter {
+			restarts = 0
+		}
+		restarts++
+		if s.opts.MaxRestarts > 0 && restarts > s.opts.MaxRestarts {
+			s.opts.Logger.Printf("[supervisor] giving up topic=%s restarts=%d err=%v", topic, restarts-1, err)
+			return fmt.Errorf("subscriber %s: gave up after %d restarts: %w", topic, restarts-1, err)
+		}
+		delay := s.opts.Backoff.backoff(restarts)
+		s.opts.Logger.Printf("[supervisor] restarting topic=%s restart=%d in=%s err=%v", topic, restarts, delay, err)
+		if sleepCtx(ctx, delay) != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"internal-pubsub/pkg/pubsub"
+)
+
+var quietSupervisor = WithSupervisorLogger(log.New(io.Discard, "", 0))
+
+var fastRestart = WithRestartBackoff(RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+func TestSupervisorRestartsFailedSubscriber(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	sup := NewSupervisor(bus, quietSupervisor, fastRestart)
+	got := make(chan any, 3)
+	var failed atomic.Bool
+	sup.Add("order.created", 4, func(ctx context.Context, ev pubsub.Event) error {
+		if failed.CompareAndSwap(false, true) {
+			return errors.New("boom")
+		}
+		got <- ev.Data
+		return nil
+	}, quiet, WithStopOnError(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sup.Start(ctx)
+	for i := range 3 {
+		_ = bus.Publish(context.Background(), "order.created", i)
+	}
+	for _, want := range []int{1, 2} {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Fatalf("got %v, want %d", v, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("subscriber was not restarted")
+		}
+	}
+
+	cancel()
+	if err := sup.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestSupervisorGivesUpAfterMaxRestarts(t *testing.T) {
+	bus := pubsub.New(pubsub.DefaultOptions())
+	defer bus.Close(context.Background())
+
+	sup := NewSupervisor(bus, quietSupervisor, fastRestart, WithMaxRestarts(2))
+	var calls atomic.Int32
+	sup.Add("order.created", 4, func(ctx context.Context, ev pubsub.Event) error {
+		calls.Add(1)
+		return errors.New("boom")
+	}, quiet, WithStopOnError(true))
+	sup.Add("invoice.generated", 1, func(ctx context.Context, ev pubsub.Event) error { return nil }, quiet)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sup.Start(ctx)
+	for i := range 3 {
+		_ = bus.Publish(context.Background(), "order.created", i)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- sup.Wait() }()
+	select {
+	case err := <-waitErr:
+		t.Fatalf("Wait returned before ctx was cancelled: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d, want 3 (initial run + 2 restarts)", calls.Load())
+	}
+
+	cancel()
+	select {
+	case err := <-waitErr:
+		if err == nil || err.Error() != "subscriber order.created: gave up after 2 restarts: boom" {
+			t.Fatalf("unexpected Wait error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after cancel")
+	}
+}